@@ -0,0 +1,510 @@
+package client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"math/rand/v2"
+	"net/rpc"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"micronet/common"
+)
+
+/**
+ * The BalanceStrategy decides which endpoint of a BalancedClient receives a call
+ */
+type BalanceStrategy int
+
+const (
+	// The endpoints are called in turn
+	RoundRobin BalanceStrategy = iota
+	// An endpoint is picked at random
+	Random
+	// The endpoint with the fewest outstanding calls is picked
+	LeastLoaded
+	// The calls with the same request key go to the same endpoint, see BalanceKey()
+	ConsistentHash
+)
+
+/**
+ * The BalancerConf configures a BalancedClient
+ * HealthInterval pings every endpoint, the failing ones are ejected for Cooldown, then pinged again to be re-admitted
+ * Replicas is the number of points of each endpoint on the ConsistentHash ring
+ * ResolveInterval refreshes the endpoints of a client created by NewResolvedClient, 0 disables it
+ * Configure is applied to the Client of every endpoint, to set its TLS config, retry policy or interceptors
+ */
+type BalancerConf struct {
+	Strategy        BalanceStrategy
+	HealthInterval  time.Duration
+	Cooldown        time.Duration
+	Replicas        int
+	ResolveInterval time.Duration
+	Configure       func(*Client)
+}
+
+/**
+ * DefaultBalancerConf calls the endpoints in turn, pings them every 5 seconds and ejects the failing ones for 30 seconds
+ * The endpoints of a resolved client are refreshed every 5 seconds
+ */
+var DefaultBalancerConf = BalancerConf{
+	Strategy:        RoundRobin,
+	HealthInterval:  5 * time.Second,
+	Cooldown:        30 * time.Second,
+	Replicas:        100,
+	ResolveInterval: 5 * time.Second,
+}
+
+/**
+ * The Keyer is implemented by requests carrying their ConsistentHash key
+ */
+type Keyer interface {
+	BalanceKey() string
+}
+
+type balanceKey struct{}
+
+/**
+ * WithBalanceKey sets the ConsistentHash key of the calls made with ctx, overriding the request's Keyer
+ */
+func WithBalanceKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, balanceKey{}, key)
+}
+
+/**
+ * The BalancedClient is an I_Client spreading calls over the replicas of a service
+ * Endpoints failing their Ping are ejected until a Ping succeeds after the cooldown
+ */
+type BalancedClient struct {
+	I_Client
+	conf      BalancerConf
+	mutex     sync.Mutex
+	updating  sync.Mutex
+	endpoints []*endpoint
+	ring      []ringPoint
+	next      atomic.Uint64
+	closed    bool
+	stop      chan struct{}
+	resolver  Resolver
+	name      string
+	unwatch   func()
+}
+
+/**
+ * The endpoint is the Client of a replica, with its load and ejection
+ */
+type endpoint struct {
+	*Client
+	netConf      common.NetConf
+	outstanding  atomic.Int64
+	ejectedUntil time.Time
+}
+
+type ringPoint struct {
+	hash     uint32
+	endpoint *endpoint
+}
+
+/**
+ * NewBalancedClient creates a BalancedClient and connects its endpoints, the unreachable ones are ejected
+ * @param networks are the replicas to call
+ * @param conf is the balancing strategy and health checking
+ * @return the initialized BalancedClient, or an error if no endpoint is reachable
+ */
+func NewBalancedClient(networks []common.NetConf, conf BalancerConf) (*BalancedClient, error) {
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no endpoint to balance")
+	}
+	conf.Replicas = max(conf.Replicas, 1)
+
+	balancer := &BalancedClient{conf: conf, stop: make(chan struct{})}
+	for _, network := range networks {
+		balancer.endpoints = append(balancer.endpoints, balancer.newEndpoint(network))
+	}
+	balancer.ring = newRing(balancer.endpoints, conf.Replicas)
+
+	errDial := balancer.Dial()
+	if errDial != nil {
+		balancer.Close()
+		return nil, errDial
+	}
+
+	if conf.HealthInterval > 0 {
+		go balancer.checkHealth()
+	}
+
+	return balancer, nil
+}
+
+func (b *BalancedClient) newEndpoint(network common.NetConf) *endpoint {
+	cli := NewLazyClient(network)
+	if b.conf.Configure != nil {
+		b.conf.Configure(cli)
+	}
+
+	return &endpoint{Client: cli, netConf: network}
+}
+
+func newRing(endpoints []*endpoint, replicas int) []ringPoint {
+	ring := make([]ringPoint, 0, len(endpoints)*replicas)
+	for _, ep := range endpoints {
+		address := ep.netConf.Ip + ":" + ep.netConf.Port
+		for i := range replicas {
+			ring = append(ring, ringPoint{hash: crc32.ChecksumIEEE([]byte(address + "#" + strconv.Itoa(i))), endpoint: ep})
+		}
+	}
+	slices.SortFunc(ring, func(a, b ringPoint) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+
+	return ring
+}
+
+/**
+ * Dial connects every endpoint, ejecting the unreachable ones
+ * @return an error joining the endpoints' errors if none is reachable
+ */
+func (b *BalancedClient) Dial() error {
+	b.mutex.Lock()
+	endpoints := b.endpoints
+	b.mutex.Unlock()
+
+	return b.dial(endpoints)
+}
+
+func (b *BalancedClient) dial(endpoints []*endpoint) error {
+	var errs []error
+	for _, ep := range endpoints {
+		errDial := ep.Dial()
+		b.setHealth(ep, errDial == nil)
+		if errDial != nil {
+			errs = append(errs, errDial)
+		}
+	}
+
+	if len(errs) == len(endpoints) {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+/**
+ * SetEndpoints replaces the replicas of the BalancedClient, keeping the connexions to the ones already known
+ * The new endpoints are connected and ejected if unreachable, the removed ones are closed once their calls are answered
+ * @param networks are the replicas to call from now on
+ */
+func (b *BalancedClient) SetEndpoints(networks []common.NetConf) {
+	b.updating.Lock()
+	defer b.updating.Unlock()
+
+	b.mutex.Lock()
+	known := make(map[common.NetConf]*endpoint, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		known[ep.netConf] = ep
+	}
+	b.mutex.Unlock()
+
+	var added []*endpoint
+	for _, network := range networks {
+		if _, ok := known[network]; !ok {
+			ep := b.newEndpoint(network)
+			known[network] = ep
+			added = append(added, ep)
+		}
+	}
+	b.dial(added)
+
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		for _, ep := range added {
+			ep.Close()
+		}
+		return
+	}
+
+	current := make(map[common.NetConf]*endpoint, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		current[ep.netConf] = ep
+	}
+	for _, ep := range added {
+		current[ep.netConf] = ep
+	}
+
+	endpoints := make([]*endpoint, 0, len(networks))
+	for _, network := range networks {
+		if ep, ok := current[network]; ok {
+			endpoints = append(endpoints, ep)
+			delete(current, network)
+		}
+	}
+	b.endpoints = endpoints
+	b.ring = newRing(endpoints, b.conf.Replicas)
+	b.mutex.Unlock()
+
+	for _, ep := range current {
+		log.Printf("Removing endpoint %+v", ep.netConf)
+		go ep.closeWhenIdle()
+	}
+}
+
+/**
+ * closeWhenIdle closes the endpoint once its outstanding calls are answered
+ */
+func (ep *endpoint) closeWhenIdle() {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for ep.outstanding.Load() > 0 {
+		<-ticker.C
+	}
+	ep.Close()
+}
+
+/**
+ * Healthy lists the endpoints currently admitted
+ */
+func (b *BalancedClient) Healthy() []common.NetConf {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var healthy []common.NetConf
+	for _, ep := range b.endpoints {
+		if ep.ejectedUntil.IsZero() {
+			healthy = append(healthy, ep.netConf)
+		}
+	}
+
+	return healthy
+}
+
+func (b *BalancedClient) setHealth(ep *endpoint, healthy bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if healthy {
+		if !ep.ejectedUntil.IsZero() {
+			log.Printf("Admitting endpoint %+v", ep.netConf)
+		}
+		ep.ejectedUntil = time.Time{}
+		return
+	}
+
+	if ep.ejectedUntil.IsZero() {
+		log.Printf("Ejecting endpoint %+v", ep.netConf)
+	}
+	ep.ejectedUntil = time.Now().Add(b.conf.Cooldown)
+}
+
+/**
+ * checkHealth pings the admitted endpoints and the ejected ones whose cooldown is over, until the client is closed
+ * The pings do not go through the RetryPolicy, an endpoint whose connexion failed is redialed once per check
+ */
+func (b *BalancedClient) checkHealth() {
+	ticker := time.NewTicker(b.conf.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case now := <-ticker.C:
+			b.mutex.Lock()
+			var due []*endpoint
+			for _, ep := range b.endpoints {
+				if !now.Before(ep.ejectedUntil) {
+					due = append(due, ep)
+				}
+			}
+			b.mutex.Unlock()
+
+			for _, ep := range due {
+				ctx, cancel := context.WithTimeout(context.Background(), b.conf.HealthInterval)
+				errPing := ep.probe(ctx)
+				cancel()
+				b.setHealth(ep, errPing == nil)
+			}
+		}
+	}
+}
+
+/**
+ * pick selects the endpoint of a call following the strategy, among the admitted endpoints
+ */
+func (b *BalancedClient) pick(ctx context.Context, serviceMethod string, request any) (*endpoint, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return nil, rpc.ErrShutdown
+	}
+
+	healthy := make([]*endpoint, 0, len(b.endpoints))
+	for _, ep := range b.endpoints {
+		if ep.ejectedUntil.IsZero() {
+			healthy = append(healthy, ep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil, common.MicronetNoEndpointError{ServiceMethod: serviceMethod}
+	}
+
+	var selected *endpoint
+	switch b.conf.Strategy {
+	case Random:
+		selected = healthy[rand.IntN(len(healthy))]
+	case LeastLoaded:
+		for _, ep := range healthy {
+			if selected == nil || ep.outstanding.Load() < selected.outstanding.Load() {
+				selected = ep
+			}
+		}
+	case ConsistentHash:
+		if key, ok := requestKey(ctx, request); ok {
+			selected = b.lookupRing(key)
+		}
+	}
+	if selected == nil {
+		selected = healthy[b.next.Add(1)%uint64(len(healthy))]
+	}
+
+	selected.outstanding.Add(1)
+
+	return selected, nil
+}
+
+func requestKey(ctx context.Context, request any) (string, bool) {
+	if key, ok := ctx.Value(balanceKey{}).(string); ok {
+		return key, true
+	}
+
+	if keyer, ok := request.(Keyer); ok {
+		return keyer.BalanceKey(), true
+	}
+
+	return "", false
+}
+
+/**
+ * lookupRing finds the first admitted endpoint following the key on the ring, the mutex must be held
+ */
+func (b *BalancedClient) lookupRing(key string) *endpoint {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start, _ := slices.BinarySearchFunc(b.ring, hash, func(point ringPoint, target uint32) int {
+		return cmp.Compare(point.hash, target)
+	})
+
+	for i := range b.ring {
+		point := b.ring[(start+i)%len(b.ring)]
+		if point.endpoint.ejectedUntil.IsZero() {
+			return point.endpoint
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Call sends a synchronous request to the endpoint selected by the strategy
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param request is the derefenced request of any type
+ * @param response is the derefenced response of any type
+ * @return a potential network error, or a common.MicronetNoEndpointError if every endpoint is ejected
+ */
+func (b *BalancedClient) Call(serviceMethod string, request any, response any) error {
+	return b.CallContext(context.Background(), serviceMethod, request, response)
+}
+
+/**
+ * Go sends an asynchronous request to the endpoint selected by the strategy
+ * See Client.Go()
+ */
+func (b *BalancedClient) Go(serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
+	return b.GoContext(context.Background(), serviceMethod, request, response, done)
+}
+
+/**
+ * CallContext sends a synchronous request to the endpoint selected by the strategy, aborting when ctx is done
+ * See Client.CallContext() and WithBalanceKey()
+ */
+func (b *BalancedClient) CallContext(ctx context.Context, serviceMethod string, request any, response any) error {
+	ep, err := b.pick(ctx, serviceMethod, request)
+	if err != nil {
+		return err
+	}
+	defer ep.outstanding.Add(-1)
+
+	return ep.CallContext(ctx, serviceMethod, request, response)
+}
+
+/**
+ * GoContext sends an asynchronous request to the endpoint selected by the strategy, aborting when ctx is done
+ * See Client.GoContext()
+ */
+func (b *BalancedClient) GoContext(ctx context.Context, serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 1)
+	} else if cap(done) == 0 {
+		log.Panic("rpc: done channel is unbuffered")
+	}
+
+	call := &rpc.Call{
+		ServiceMethod: serviceMethod,
+		Args:          request,
+		Reply:         response,
+		Done:          done,
+	}
+
+	go func() {
+		call.Error = b.CallContext(ctx, serviceMethod, request, response)
+		call.Done <- call
+	}()
+
+	return call
+}
+
+/**
+ * Ping tests the connexion to the endpoint selected by the strategy
+ */
+func (b *BalancedClient) Ping() error {
+	ep, err := b.pick(context.Background(), "PingHandler.Ping", nil)
+	if err != nil {
+		return err
+	}
+	defer ep.outstanding.Add(-1)
+
+	return ep.Ping()
+}
+
+/**
+ * Close stops the health checks and closes every endpoint
+ */
+func (b *BalancedClient) Close() error {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return fmt.Errorf("balanced client already closed")
+	}
+	b.closed = true
+	close(b.stop)
+	endpoints := b.endpoints
+	unwatch := b.unwatch
+	b.mutex.Unlock()
+
+	if unwatch != nil {
+		unwatch()
+	}
+	for _, ep := range endpoints {
+		ep.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type EndpointService struct {
+	port string
+}
+
+func (s *EndpointService) Name(req string, resp *string) error {
+	*resp = s.port
+	return nil
+}
+
+func (s *EndpointService) Slow(req time.Duration, resp *string) error {
+	time.Sleep(req)
+	*resp = s.port
+	return nil
+}
+
+type keyedRequest string
+
+func (r keyedRequest) BalanceKey() string {
+	return string(r)
+}
+
+/**
+ * startEndpoint serves an EndpointService answering its port
+ * @return the endpoint's config and a function closing the listener and connexions
+ */
+func startEndpoint(t *testing.T, port string) (common.NetConf, func()) {
+	srv := rpc.NewServer()
+	srv.Register(&EndpointService{port: port})
+	srv.Register(new(common.PingHandler))
+
+	listener, errListen := net.Listen("tcp", "127.0.0.1:"+port)
+	if errListen != nil {
+		t.Fatal(errListen)
+	}
+
+	var mutex sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+			go srv.ServeConn(conn)
+		}
+	}()
+
+	kill := func() {
+		listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	t.Cleanup(kill)
+
+	return common.NetConf{Name: "replica", Protocol: "tcp", Ip: "127.0.0.1", Port: port}, kill
+}
+
+func startReplicas(t *testing.T, ports ...string) ([]common.NetConf, []func()) {
+	var networks []common.NetConf
+	var kills []func()
+	for _, port := range ports {
+		network, kill := startEndpoint(t, port)
+		networks = append(networks, network)
+		kills = append(kills, kill)
+	}
+
+	return networks, kills
+}
+
+func callName(t *testing.T, cli I_Client, request any) string {
+	var resp string
+	assert.NoError(t, cli.Call("EndpointService.Name", request, &resp))
+	return resp
+}
+
+func TestBalancedClient_Strategies(t *testing.T) {
+	networks, _ := startReplicas(t, "12362", "12363", "12364")
+
+	t.Run("Round robin", func(t *testing.T) {
+		balancer, err := NewBalancedClient(networks, BalancerConf{Strategy: RoundRobin})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		counts := make(map[string]int)
+		for range 9 {
+			counts[callName(t, balancer, "")]++
+		}
+		assert.Equal(t, map[string]int{"12362": 3, "12363": 3, "12364": 3}, counts)
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		balancer, err := NewBalancedClient(networks, BalancerConf{Strategy: Random})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		counts := make(map[string]int)
+		for range 100 {
+			counts[callName(t, balancer, "")]++
+		}
+		assert.Len(t, counts, 3)
+	})
+
+	t.Run("Least loaded", func(t *testing.T) {
+		balancer, err := NewBalancedClient(networks, BalancerConf{Strategy: LeastLoaded})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		busy := new(string)
+		slow := balancer.Go("EndpointService.Slow", 200*time.Millisecond, busy, nil)
+		time.Sleep(20 * time.Millisecond)
+
+		var names []string
+		for range 5 {
+			names = append(names, callName(t, balancer, ""))
+		}
+
+		assert.NoError(t, (<-slow.Done).Error)
+		assert.NotContains(t, names, *busy)
+	})
+
+	t.Run("Consistent hash", func(t *testing.T) {
+		balancer, err := NewBalancedClient(networks, BalancerConf{Strategy: ConsistentHash})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		owners := make(map[string]string)
+		for _, key := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+			owners[key] = callName(t, balancer, keyedRequest(key))
+		}
+
+		for key, owner := range owners {
+			assert.Equal(t, owner, callName(t, balancer, keyedRequest(key)))
+
+			var resp string
+			ctx := WithBalanceKey(context.Background(), key)
+			assert.NoError(t, balancer.CallContext(ctx, "EndpointService.Name", "", &resp))
+			assert.Equal(t, owner, resp)
+		}
+	})
+}
+
+func TestBalancedClient_Health(t *testing.T) {
+	t.Run("Failing endpoint is ejected then re-admitted", func(t *testing.T) {
+		networks, kills := startReplicas(t, "12365", "12366")
+
+		balancer, err := NewBalancedClient(networks, BalancerConf{
+			HealthInterval: 50 * time.Millisecond,
+			Cooldown:       100 * time.Millisecond,
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		kills[0]()
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual([]common.NetConf{networks[1]}, balancer.Healthy())
+		}, time.Second, 10*time.Millisecond)
+
+		for range 4 {
+			assert.Equal(t, "12366", callName(t, balancer, ""))
+		}
+
+		startEndpoint(t, "12365")
+		assert.Eventually(t, func() bool { return len(balancer.Healthy()) == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Unreachable endpoints are ejected on creation", func(t *testing.T) {
+		network, _ := startEndpoint(t, "12367")
+		unreachable := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "12368"}
+
+		balancer, err := NewBalancedClient([]common.NetConf{unreachable, network}, BalancerConf{Cooldown: time.Minute})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		assert.Equal(t, []common.NetConf{network}, balancer.Healthy())
+		assert.Equal(t, "12367", callName(t, balancer, ""))
+		assert.NoError(t, balancer.Ping())
+	})
+
+	t.Run("No endpoint reachable", func(t *testing.T) {
+		unreachable := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "12368"}
+
+		_, err := NewBalancedClient([]common.NetConf{unreachable}, DefaultBalancerConf)
+		assert.Error(t, err)
+	})
+
+	t.Run("Every endpoint ejected", func(t *testing.T) {
+		networks, kills := startReplicas(t, "12369")
+
+		balancer, err := NewBalancedClient(networks, BalancerConf{
+			HealthInterval: 50 * time.Millisecond,
+			Cooldown:       time.Minute,
+			Configure: func(cli *Client) {
+				cli.SetReconnectionConf(1, 0)
+			},
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer balancer.Close()
+
+		kills[0]()
+		assert.Eventually(t, func() bool { return len(balancer.Healthy()) == 0 }, time.Second, 10*time.Millisecond)
+
+		err = balancer.Call("EndpointService.Name", "", new(string))
+		assert.Equal(t, common.MicronetNoEndpointError{ServiceMethod: "EndpointService.Name"}, err)
+		assert.Equal(t, common.Unavailable, common.CodeOf(err))
+	})
+}
+
+func TestBalancedClient_SetEndpoints(t *testing.T) {
+	networks, _ := startReplicas(t, "12371", "12372")
+
+	balancer, err := NewBalancedClient(networks[:1], BalancerConf{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer balancer.Close()
+
+	slow := balancer.Go("EndpointService.Slow", 100*time.Millisecond, new(string), nil)
+	time.Sleep(20 * time.Millisecond)
+
+	balancer.SetEndpoints(networks[1:])
+	assert.Equal(t, networks[1:], balancer.Healthy())
+	for range 4 {
+		assert.Equal(t, "12372", callName(t, balancer, ""))
+	}
+
+	// the removed endpoint answers its call before being closed
+	call := <-slow.Done
+	assert.NoError(t, call.Error)
+	assert.Equal(t, "12371", *call.Reply.(*string))
+
+	balancer.SetEndpoints(networks)
+	assert.Equal(t, networks, balancer.Healthy())
+}
@@ -0,0 +1,303 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"micronet/common"
+)
+
+// The number of buckets of a CircuitBreaker's rolling window
+const breakerBuckets = 10
+
+/**
+ * The BreakerState is the state of a CircuitBreaker
+ */
+type BreakerState int
+
+const (
+	// Calls go through and their failures are counted
+	BreakerClosed BreakerState = iota
+	// Calls fail fast until OpenTimeout elapses
+	BreakerOpen
+	// A few probe calls go through to test the remote
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+/**
+ * The BreakerConf configures a CircuitBreaker
+ * The breaker opens when FailureRatio of the calls of the last Window failed, once at least MinCalls were made
+ * and at least one of them failed, a zero FailureRatio or Window defaults to DefaultBreakerConf's
+ * After OpenTimeout it lets Probes calls through, and closes if they all succeed or opens again on the first failure
+ * IsFailure overrides IsBreakerFailure when set, OnStateChange is called on every transition
+ */
+type BreakerConf struct {
+	FailureRatio  float64
+	MinCalls      int
+	Window        time.Duration
+	OpenTimeout   time.Duration
+	Probes        int
+	IsFailure     func(error) bool
+	OnStateChange func(from BreakerState, to BreakerState)
+}
+
+/**
+ * DefaultBreakerConf opens when half of at least 10 calls failed in 10 seconds, and probes with 3 calls after 5 seconds
+ */
+var DefaultBreakerConf = BreakerConf{
+	FailureRatio: 0.5,
+	MinCalls:     10,
+	Window:       10 * time.Second,
+	OpenTimeout:  5 * time.Second,
+	Probes:       3,
+}
+
+/**
+ * IsBreakerFailure is the default failure predicate: connection failures, unavailable remotes and timeouts
+ * Errors returned by the remote handler are successes, as the remote did answer
+ */
+func IsBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch common.CodeOf(err) {
+	case common.Unavailable, common.DeadlineExceeded:
+		return true
+	}
+
+	return IsRetryable(err)
+}
+
+/**
+ * The CircuitBreaker fails calls fast while their remote is failing
+ */
+type CircuitBreaker struct {
+	conf           BreakerConf
+	mutex          sync.Mutex
+	state          BreakerState
+	openedAt       time.Time
+	buckets        [breakerBuckets]breakerBucket
+	probes         int
+	probeSuccesses int
+	changes        []stateChange
+}
+
+/**
+ * The stateChange is a transition waiting to be notified once the mutex is released
+ */
+type stateChange struct {
+	from BreakerState
+	to   BreakerState
+}
+
+/**
+ * The breakerBucket counts the calls of a slice of the window
+ */
+type breakerBucket struct {
+	start    time.Time
+	calls    int
+	failures int
+}
+
+/**
+ * NewCircuitBreaker creates a closed CircuitBreaker
+ * @param conf is the opening threshold and the probing
+ */
+func NewCircuitBreaker(conf BreakerConf) *CircuitBreaker {
+	conf.Probes = max(conf.Probes, 1)
+	conf.MinCalls = max(conf.MinCalls, 1)
+	if conf.FailureRatio <= 0 {
+		conf.FailureRatio = DefaultBreakerConf.FailureRatio
+	}
+	if conf.Window <= 0 {
+		conf.Window = DefaultBreakerConf.Window
+	}
+	if conf.IsFailure == nil {
+		conf.IsFailure = IsBreakerFailure
+	}
+
+	return &CircuitBreaker{conf: conf}
+}
+
+/**
+ * State is the breaker's current state, an open breaker whose OpenTimeout elapsed is half-open
+ */
+func (b *CircuitBreaker) State() BreakerState {
+	b.mutex.Lock()
+	defer b.notify()
+	defer b.mutex.Unlock()
+
+	b.expire(time.Now())
+	return b.state
+}
+
+/**
+ * allow reserves a call, or refuses it while the breaker is open or its probes are all running
+ * @return true if the call may go through, and must then be recorded
+ */
+func (b *CircuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.notify()
+	defer b.mutex.Unlock()
+
+	b.expire(time.Now())
+
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.probes >= b.conf.Probes {
+			return false
+		}
+		b.probes++
+	}
+
+	return true
+}
+
+/**
+ * record counts the result of an allowed call
+ * @param err is the call's error
+ */
+func (b *CircuitBreaker) record(err error) {
+	failed := b.conf.IsFailure(err)
+
+	b.mutex.Lock()
+	defer b.notify()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.transition(BreakerOpen, now)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.conf.Probes {
+			b.transition(BreakerClosed, now)
+		}
+
+	case BreakerClosed:
+		bucket := b.bucket(now)
+		bucket.calls++
+		if failed {
+			bucket.failures++
+		}
+
+		calls, failures := b.count(now)
+		if calls >= b.conf.MinCalls && failures > 0 && float64(failures) >= b.conf.FailureRatio*float64(calls) {
+			b.transition(BreakerOpen, now)
+		}
+	}
+}
+
+/**
+ * expire half-opens the breaker once its OpenTimeout elapsed, the mutex must be held
+ */
+func (b *CircuitBreaker) expire(now time.Time) {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.conf.OpenTimeout {
+		b.transition(BreakerHalfOpen, now)
+	}
+}
+
+/**
+ * transition changes the state and resets its counters, the mutex must be held
+ */
+func (b *CircuitBreaker) transition(to BreakerState, now time.Time) {
+	from := b.state
+	b.state = to
+	b.probes, b.probeSuccesses = 0, 0
+
+	switch to {
+	case BreakerOpen:
+		b.openedAt = now
+	case BreakerClosed:
+		b.buckets = [breakerBuckets]breakerBucket{}
+	}
+
+	if from != to {
+		b.changes = append(b.changes, stateChange{from: from, to: to})
+	}
+}
+
+/**
+ * notify calls OnStateChange for the pending transitions, outside of the mutex so that it may use the breaker
+ */
+func (b *CircuitBreaker) notify() {
+	b.mutex.Lock()
+	changes := b.changes
+	b.changes = nil
+	b.mutex.Unlock()
+
+	if b.conf.OnStateChange == nil {
+		return
+	}
+	for _, change := range changes {
+		b.conf.OnStateChange(change.from, change.to)
+	}
+}
+
+/**
+ * bucket is the bucket of the current slice of the window, reset if it held an older slice
+ */
+func (b *CircuitBreaker) bucket(now time.Time) *breakerBucket {
+	width := max(b.conf.Window/breakerBuckets, time.Nanosecond)
+	start := now.Truncate(width)
+
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+
+	return bucket
+}
+
+/**
+ * count sums the calls of the window
+ */
+func (b *CircuitBreaker) count(now time.Time) (calls int, failures int) {
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.conf.Window {
+			calls += bucket.calls
+			failures += bucket.failures
+		}
+	}
+
+	return calls, failures
+}
+
+/**
+ * SetCircuitBreaker puts a new CircuitBreaker in front of the Client's calls, so that they fail fast while the remote is down
+ * @param conf is the opening threshold and the probing
+ */
+func (c *Client) SetCircuitBreaker(conf BreakerConf) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.breaker = NewCircuitBreaker(conf)
+}
+
+/**
+ * CircuitBreaker is the Client's breaker, nil if none was set
+ */
+func (c *Client) CircuitBreaker() *CircuitBreaker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.breaker
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errUnavailable = common.MicronetReconnectTimeoutError{}
+
+func TestCircuitBreaker_States(t *testing.T) {
+	var mutex sync.Mutex
+	var transitions []string
+	breaker := NewCircuitBreaker(BreakerConf{
+		FailureRatio: 0.5,
+		MinCalls:     5,
+		Window:       time.Second,
+		OpenTimeout:  50 * time.Millisecond,
+		Probes:       2,
+		OnStateChange: func(from BreakerState, to BreakerState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			transitions = append(transitions, from.String()+">"+to.String())
+		},
+	})
+
+	// application errors are not failures
+	for i := 0; i < 3; i++ {
+		assert.True(t, breaker.allow())
+		breaker.record(errors.New("application error"))
+	}
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// 2 failures out of 5 calls
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// 3 failures out of 6 calls
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.allow())
+
+	// only Probes calls go through once half-open
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.True(t, breaker.allow())
+	assert.True(t, breaker.allow())
+	assert.False(t, breaker.allow())
+
+	// a failed probe opens again
+	breaker.record(nil)
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	// successful probes close
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		assert.True(t, breaker.allow())
+		breaker.record(nil)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{
+		"closed>open",
+		"open>half-open",
+		"half-open>open",
+		"open>half-open",
+		"half-open>closed",
+	}, transitions)
+}
+
+func TestCircuitBreaker_Window(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConf{
+		FailureRatio: 0.5,
+		MinCalls:     2,
+		Window:       50 * time.Millisecond,
+		OpenTimeout:  time.Second,
+	})
+
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+
+	// the first failure left the window
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.True(t, breaker.allow())
+	breaker.record(errUnavailable)
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestCircuitBreaker_Defaults(t *testing.T) {
+	breaker := NewCircuitBreaker(BreakerConf{MinCalls: 2, OpenTimeout: time.Second})
+	assert.Equal(t, DefaultBreakerConf.FailureRatio, breaker.conf.FailureRatio)
+	assert.Equal(t, DefaultBreakerConf.Window, breaker.conf.Window)
+
+	// successes alone never open the breaker
+	for range 5 {
+		assert.True(t, breaker.allow())
+		breaker.record(nil)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// the calls are counted within the default window
+	for range 5 {
+		assert.True(t, breaker.allow())
+		breaker.record(errUnavailable)
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	network, kill := startEndpoint(t, "12370")
+
+	cli, errNew := NewClient(network)
+	if !assert.NoError(t, errNew) {
+		return
+	}
+	defer cli.Close()
+	cli.SetReconnectionConf(1, 0)
+
+	states := make(chan BreakerState, 10)
+	cli.SetCircuitBreaker(BreakerConf{
+		FailureRatio: 0.6,
+		MinCalls:     3,
+		Window:       time.Second,
+		OpenTimeout:  100 * time.Millisecond,
+		Probes:       1,
+		OnStateChange: func(from BreakerState, to BreakerState) {
+			states <- to
+		},
+	})
+
+	assert.Equal(t, "12370", callName(t, cli, "a"))
+
+	kill()
+	var resp string
+	for i := 0; i < 2; i++ {
+		err := cli.Call("EndpointService.Name", "a", &resp)
+		assert.Error(t, err)
+		assert.NotErrorAs(t, err, new(common.MicronetCircuitOpenError))
+	}
+	assert.Equal(t, BreakerOpen, <-states)
+
+	// fails fast while open
+	err := cli.Call("EndpointService.Name", "a", &resp)
+	assert.ErrorAs(t, err, new(common.MicronetCircuitOpenError))
+	assert.Equal(t, common.Unavailable, common.CodeOf(err))
+
+	// the probe reconnects to the restarted remote and closes the breaker
+	startEndpoint(t, "12370")
+	time.Sleep(110 * time.Millisecond)
+	assert.Equal(t, "12370", callName(t, cli, "a"))
+	assert.Equal(t, BreakerHalfOpen, <-states)
+	assert.Equal(t, BreakerClosed, <-states)
+	assert.Equal(t, BreakerClosed, cli.CircuitBreaker().State())
+}
@@ -1,11 +1,17 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/rpc"
+	"sync"
 	"time"
 
+	"micronet/codec"
 	"micronet/common"
 )
 
@@ -16,20 +22,35 @@ type I_Client interface {
 	Dial() error
 	Call(string, any, any) error
 	Go(string, any, any, chan *rpc.Call) *rpc.Call
+	CallContext(context.Context, string, any, any) error
+	GoContext(context.Context, string, any, any, chan *rpc.Call) *rpc.Call
 	Close() error
 	Ping() error
 }
 
 /**
  * The Client structure is an rpc client with the remote server's config
+ * The embedded rpc.Client is swapped on reconnection, it should not be used directly while calls are running
  */
 type Client struct {
 	*rpc.Client
 	I_Client
-	remote         common.NetConf
-	isReconnecting bool
-	iterationLimit int
-	timeInterval   time.Duration
+	remote       common.NetConf
+	retryPolicy  RetryPolicy
+	mutex        sync.Mutex
+	reconnection *reconnection
+	closed       bool
+	tlsConfig    *tls.Config
+	interceptors []Interceptor
+	breaker      *CircuitBreaker
+}
+
+/**
+ * The reconnection is a redial shared by every call that failed on the same connection
+ */
+type reconnection struct {
+	done chan struct{}
+	err  error
 }
 
 /**
@@ -47,26 +68,138 @@ func NewClient(network common.NetConf) (*Client, error) {
 		return nil, errDial
 	}
 
-	cli.SetReconnectionConf(3, 1)
+	cli.SetRetryPolicy(DefaultRetryPolicy)
 
 	return cli, nil
 }
 
 /**
- * Dial creates the client's connexion to the remote Server
+ * NewTLSClient creates an rpc client connected to the remote server over TLS
+ * Add a client certificate to config for mutual TLS
+ * @param network is the remote server to call
+ * @param config is the client's TLS configuration
+ * @return the initialized Client
+ */
+func NewTLSClient(network common.NetConf, config *tls.Config) (*Client, error) {
+	cli := NewLazyClient(network)
+	cli.SetTLSConfig(config)
+
+	errDial := cli.Dial()
+	if errDial != nil {
+		return nil, errDial
+	}
+
+	return cli, nil
+}
+
+/**
+ * NewLazyClient creates an rpc client without connecting it
+ * The connexion is established by the first call, following the RetryPolicy
+ * @param network is the remote server to call
+ * @return the initialized Client
+ */
+func NewLazyClient(network common.NetConf) *Client {
+	cli := &Client{
+		remote: network,
+	}
+	cli.SetRetryPolicy(DefaultRetryPolicy)
+
+	return cli
+}
+
+/**
+ * Dial creates the client's connexion to the remote Server, replacing and closing the previous one
+ * The codec, protocol version and compression are negotiated first when the remote's NetConf enables the Handshake
+ * or a Compression
  * You should use NewClient instead, it will Dial for you.
  * @return a potential network error
  */
 func (c *Client) Dial() error {
-	var err error
-	c.Client, err = rpc.Dial(c.remote.Protocol, c.remote.Ip+":"+c.remote.Port)
+	conn, err := c.dial()
 	if err != nil {
 		return err
 	}
 
+	c.mutex.Lock()
+	previous := c.Client
+	c.Client = conn
+	c.closed = false
+	c.mutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
 	return nil
 }
 
+/**
+ * SetTLSConfig makes the next connexions use TLS, the current one is kept
+ * @param config is the client's TLS configuration, nil dials plain TCP
+ */
+func (c *Client) SetTLSConfig(config *tls.Config) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.tlsConfig = config
+}
+
+func (c *Client) dial() (*rpc.Client, error) {
+	return c.dialContext(context.Background())
+}
+
+/**
+ * dialContext opens a new connexion to the remote, ctx bounds the connection but not the handshake
+ */
+func (c *Client) dialContext(ctx context.Context) (*rpc.Client, error) {
+	c.mutex.Lock()
+	config := c.tlsConfig
+	c.mutex.Unlock()
+
+	handshake := c.remote.Handshake || c.remote.Compression != ""
+
+	var clientCodec codec.Codec
+	var err error
+	if !handshake {
+		clientCodec, err = codec.Lookup(c.remote.Codec)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var conn net.Conn
+	address := c.remote.Ip + ":" + c.remote.Port
+	if config == nil {
+		conn, err = new(net.Dialer).DialContext(ctx, c.remote.Protocol, address)
+	} else {
+		conn, err = (&tls.Dialer{Config: config}).DialContext(ctx, c.remote.Protocol, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if handshake {
+		negotiated, negotiatedCodec, errNegotiate := c.negotiate(conn)
+		if errNegotiate != nil {
+			conn.Close()
+			return nil, errNegotiate
+		}
+		conn, clientCodec = negotiated, negotiatedCodec
+	}
+
+	return rpc.NewClientWithCodec(clientCodec.NewClientCodec(conn)), nil
+}
+
+/**
+ * conn returns the current connexion, nil if the client is not connected
+ */
+func (c *Client) conn() *rpc.Client {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.Client
+}
+
 /**
  * Call sends a synchronous request to the remote Server
  * Use Go() for async request
@@ -76,20 +209,7 @@ func (c *Client) Dial() error {
  * @return a potential network error
  */
 func (c *Client) Call(serviceMethod string, request any, response any) error {
-	if c.Client == nil {
-		return fmt.Errorf("nil client")
-	}
-
-	errCall := c.Client.Call(serviceMethod, request, response)
-	if errCall == nil {
-		return nil
-	}
-
-	if errReconnect := c.reconnect(); errReconnect != nil {
-		return errReconnect
-	}
-
-	return c.Client.Call(serviceMethod, request, response)
+	return c.CallContext(context.Background(), serviceMethod, request, response)
 }
 
 /**
@@ -104,66 +224,78 @@ func (c *Client) Call(serviceMethod string, request any, response any) error {
  * @return the done channel
  */
 func (c *Client) Go(serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
-	if c.Client == nil {
-		call := &rpc.Call{
-			ServiceMethod: serviceMethod,
-			Args:          request,
-			Reply:         response,
-			Error:         fmt.Errorf("nil client"),
-			Done:          make(chan *rpc.Call, 1),
-		}
-		call.Done <- call
-		return call
-	}
+	return c.GoContext(context.Background(), serviceMethod, request, response, done)
+}
+
+/**
+ * CallContext sends a synchronous request to the remote Server, aborting when ctx is done
+ * An aborted call returns a common.MicronetTimeoutError and does not trigger a reconnection.
+ * The response may still be written by a late reply, so it should not be reused after an abort
+ * @param ctx bounds the call, including reconnection attempts
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param request is the derefenced request of any type
+ * @param response is the derefenced response of any type
+ * @return a potential network or timeout error
+ */
+func (c *Client) CallContext(ctx context.Context, serviceMethod string, request any, response any) error {
+	call := c.GoContext(ctx, serviceMethod, request, response, make(chan *rpc.Call, 1))
+	<-call.Done
 
-	// first async attempt
-	origCall := c.Client.Go(serviceMethod, request, response, done)
+	return call.Error
+}
 
-	// The call returned to the user
-	wrappedCall := &rpc.Call{
+/**
+ * GoContext sends a asynchronous request to the remote Server, aborting when ctx is done
+ * See CallContext() for the abort semantics
+ * @param ctx bounds the call, including reconnection attempts
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param request is the derefenced request of any type
+ * @param response is the derefenced response of any type
+ * @param done channel will signal when the call is complete by returning the same Call object.
+ * 		If done is nil, GoContext will allocate a new channel.
+ * 		If non-nil, done must be buffered or GoContext will deliberately crash
+ * @return the done channel
+ */
+func (c *Client) GoContext(ctx context.Context, serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 1)
+	} else if cap(done) == 0 {
+		log.Panic("rpc: done channel is unbuffered")
+	}
+
+	call := &rpc.Call{
 		ServiceMethod: serviceMethod,
 		Args:          request,
 		Reply:         response,
-		Done:          make(chan *rpc.Call, 1),
+		Done:          done,
 	}
 
-	go func() {
-		result := <-origCall.Done
+	info := &CallInfo{ServiceMethod: serviceMethod, Args: request, Reply: response, Remote: c.remote}
 
-		// If first attempt succeeded
-		if result.Error == nil {
-			wrappedCall.Error = nil
-			wrappedCall.Done <- wrappedCall
-			return
-		}
-
-		// Try reconnect
-		if err := c.reconnect(); err != nil {
-			wrappedCall.Error = result.Error // propagate error
-			wrappedCall.Done <- wrappedCall
-			return
-		}
-
-		// Retry
-		retryCall := c.Client.Go(serviceMethod, request, response, nil)
-		retryResult := <-retryCall.Done
-
-		wrappedCall.Error = retryResult.Error // propagate retry error
-		wrappedCall.Done <- wrappedCall
+	go func() {
+		call.Error = c.intercept(ctx, info, func(ctx context.Context) error {
+			return c.guard(ctx, serviceMethod, request, response)
+		})
+		call.Done <- call
 	}()
 
-	return wrappedCall
+	return call
 }
 
 /**
  * Close calls the underlying codec's Close method. If the connection is already shutting down, ErrShutdown is returned
  */
 func (c *Client) Close() error {
-	if c.Client == nil {
+	c.mutex.Lock()
+	conn := c.Client
+	c.closed = true
+	c.mutex.Unlock()
+
+	if conn == nil {
 		return fmt.Errorf("nil client")
 	}
 
-	err := c.Client.Close()
+	err := conn.Close()
 	if err != nil {
 		return err
 	}
@@ -176,10 +308,6 @@ func (c *Client) Close() error {
  * It is registered by default by the Server
  */
 func (c *Client) Ping() error {
-	if c.Client == nil {
-		return fmt.Errorf("nil client")
-	}
-
 	request := common.Ping{Data: common.PING}
 	response := common.Pong{}
 	err := c.Call("PingHandler.Ping", &request, &response)
@@ -197,43 +325,245 @@ func (c *Client) Ping() error {
 }
 
 /**
- * Set reconnection logic
+ * Set reconnection logic with a ConstantBackoff
  * @param iterationLimit is the number of times the reconnection should try
- * @param timeInterval is the time (in seconds) between each try
+ * @param timeInterval is the time between each try
  */
 func (c *Client) SetReconnectionConf(iterationLimit int, timeInterval time.Duration) {
+	c.SetRetryPolicy(ConstantBackoff{
+		RetryLimits: RetryLimits{MaxAttempts: iterationLimit},
+		Interval:    timeInterval,
+	})
+}
+
+/**
+ * Set the reconnection policy
+ * @param policy decides which failed calls are retried and how the reconnection is paced
+ */
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
 	if c == nil {
 		return
 	}
 
-	c.iterationLimit = iterationLimit
-	c.timeInterval = timeInterval
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.retryPolicy = policy
 }
 
-func (c *Client) reconnect() error {
-	if c.Client == nil {
-		return fmt.Errorf("nil client")
+func (c *Client) policy() RetryPolicy {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.retryPolicy == nil {
+		return DefaultRetryPolicy
+	}
+
+	return c.retryPolicy
+}
+
+/**
+ * call sends the request, then reconnects and retries once if the failure is retryable
+ * A client that is not connected yet reconnects before the first attempt
+ */
+func (c *Client) call(ctx context.Context, serviceMethod string, request any, response any) error {
+	c.mutex.Lock()
+	conn, closed := c.Client, c.closed
+	c.mutex.Unlock()
+
+	if closed {
+		return rpc.ErrShutdown
+	}
+
+	if conn != nil {
+		errCall := c.attempt(ctx, conn, serviceMethod, request, response)
+		if errCall == nil {
+			return nil
+		}
+
+		var errTimeout common.MicronetTimeoutError
+		if errors.As(errCall, &errTimeout) || !c.policy().Retryable(errCall) {
+			return errCall
+		}
+	}
+
+	if errReconnect := c.reconnect(ctx, conn, serviceMethod); errReconnect != nil {
+		return errReconnect
 	}
 
-	if c.isReconnecting {
+	return c.attempt(ctx, c.conn(), serviceMethod, request, response)
+}
+
+/**
+ * guard sends the request through the circuit breaker, if any
+ * @return a common.MicronetCircuitOpenError while the breaker is open, the call's error otherwise
+ */
+func (c *Client) guard(ctx context.Context, serviceMethod string, request any, response any) error {
+	breaker := c.CircuitBreaker()
+	if breaker == nil {
+		return c.call(ctx, serviceMethod, request, response)
+	}
+
+	if !breaker.allow() {
+		return common.MicronetCircuitOpenError{NetConf: c.remote}
+	}
+
+	err := c.call(ctx, serviceMethod, request, response)
+	breaker.record(err)
+
+	return err
+}
+
+/**
+ * attempt sends the request once over the given connexion
+ */
+func (c *Client) attempt(ctx context.Context, conn *rpc.Client, serviceMethod string, request any, response any) error {
+	call := conn.Go(serviceMethod, request, response, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		return common.DecodeError(call.Error)
+	case <-ctx.Done():
+		return common.MicronetTimeoutError{NetConf: c.remote, ServiceMethod: serviceMethod, Err: ctx.Err()}
+	}
+}
+
+/**
+ * probe pings the remote without the interceptors, the circuit breaker and the RetryPolicy, for the health checks
+ * A client without connexion, or whose connexion failed, dials the remote once
+ * @param ctx bounds the ping and the dial
+ * @return the ping's error, rpc.ErrShutdown once the client is closed
+ */
+func (c *Client) probe(ctx context.Context) error {
+	ping := func(conn *rpc.Client) error {
+		return c.attempt(ctx, conn, "PingHandler.Ping", &common.Ping{Data: common.PING}, &common.Pong{})
+	}
+
+	c.mutex.Lock()
+	conn, closed := c.Client, c.closed
+	c.mutex.Unlock()
+
+	if closed {
+		return rpc.ErrShutdown
+	}
+
+	if conn != nil {
+		errPing := ping(conn)
+		if errPing == nil || !IsRetryable(errPing) {
+			return errPing
+		}
+	}
+
+	fresh, errDial := c.dialContext(ctx)
+	if errDial != nil {
+		return errDial
+	}
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		fresh.Close()
+		return rpc.ErrShutdown
+	}
+	if c.Client != conn {
+		// reconnected meanwhile
+		current := c.Client
+		c.mutex.Unlock()
+		fresh.Close()
+		return ping(current)
+	}
+	c.Client = fresh
+	c.mutex.Unlock()
+
+	if conn != nil {
+		conn.Close()
+	}
+
+	return ping(fresh)
+}
+
+/**
+ * reconnect replaces the failed connexion
+ * Concurrent callers share a single redial, and a caller whose connexion was already replaced returns immediately
+ * @param failed is the connexion the caller's request failed on
+ */
+func (c *Client) reconnect(ctx context.Context, failed *rpc.Client, serviceMethod string) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return rpc.ErrShutdown
+	}
+	if c.Client != nil && c.Client != failed {
+		c.mutex.Unlock()
 		return nil
 	}
-	c.isReconnecting = true
 
-	for i := 0; i < c.iterationLimit; i++ {
-		log.Printf("reconnexion attempt %d/%d to %+v\n", i+1, c.iterationLimit, c.remote)
+	current := c.reconnection
+	if current == nil {
+		current = &reconnection{done: make(chan struct{})}
+		c.reconnection = current
+		go c.redial(current)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-current.done:
+		return current.err
+	case <-ctx.Done():
+		return common.MicronetTimeoutError{NetConf: c.remote, ServiceMethod: serviceMethod, Err: ctx.Err()}
+	}
+}
+
+/**
+ * redial runs the RetryPolicy until a new connexion is established, then swaps it with the failed one
+ * It does not depend on any caller's context, as other callers may be waiting for it
+ */
+func (c *Client) redial(current *reconnection) {
+	defer close(current.done)
+
+	start := time.Now()
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		var ok bool
+		delay, ok = c.policy().Next(attempt, delay, time.Since(start))
+		if !ok {
+			break
+		}
+		time.Sleep(delay)
+
+		log.Printf("reconnexion attempt %d to %+v\n", attempt, c.remote)
 
-		if err := c.Dial(); err != nil {
+		conn, err := c.dial()
+		if err != nil {
 			log.Println("reconnect failed:", err)
-		} else {
-			log.Println("reconnexion succeeded")
-			c.isReconnecting = false
-			return nil
+			continue
 		}
 
-		time.Sleep(time.Second * c.timeInterval)
+		c.mutex.Lock()
+		previous := c.Client
+		closed := c.closed
+		if !closed {
+			c.Client = conn
+		}
+		c.reconnection = nil
+		c.mutex.Unlock()
+
+		if closed {
+			conn.Close()
+			current.err = rpc.ErrShutdown
+			return
+		}
+		if previous != nil {
+			previous.Close()
+		}
+
+		log.Println("reconnexion succeeded")
+		return
 	}
 
-	c.isReconnecting = false
-	return common.MicronetReconnectTimeoutError{NetConf: c.remote}
+	c.mutex.Lock()
+	c.reconnection = nil
+	c.mutex.Unlock()
+
+	current.err = common.MicronetReconnectTimeoutError{NetConf: c.remote}
 }
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"micronet/common"
 	"net"
 	"net/rpc"
@@ -28,6 +30,12 @@ func (s *MockService) MockMethod(req bool, resp *int) error {
 	return nil
 }
 
+func (s *MockService) SlowMethod(req time.Duration, resp *int) error {
+	time.Sleep(req)
+	*resp = MockMethodResponseValue
+	return nil
+}
+
 func TestClient_NewClient(t *testing.T) {
 	t.Run("Client connection success", func(t *testing.T) {
 		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
@@ -528,3 +536,315 @@ func TestClient_Go(t *testing.T) {
 		assert.Equal(t, MockMethodResponseValue, response)
 	})
 }
+
+func TestClient_CallContext(t *testing.T) {
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+		rpcClient := client.Client
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var response int
+		errCall := client.CallContext(ctx, "MockService.SlowMethod", time.Second, &response)
+
+		var errTimeout common.MicronetTimeoutError
+		assert.True(t, errors.As(errCall, &errTimeout))
+		assert.ErrorIs(t, errCall, context.DeadlineExceeded)
+		assert.Equal(t, "MockService.SlowMethod", errTimeout.ServiceMethod)
+
+		// a timeout must not trigger the reconnection
+		assert.Same(t, rpcClient, client.Client)
+
+		var nextResponse int
+		errNext := client.Call("MockService.MockMethod", true, &nextResponse)
+		assert.NoError(t, errNext)
+		assert.Equal(t, MockMethodResponseValue, nextResponse)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var response int
+		errCall := client.CallContext(ctx, "MockService.SlowMethod", time.Second, &response)
+
+		assert.ErrorIs(t, errCall, context.Canceled)
+	})
+
+	t.Run("Nominal case", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var response int
+		errCall := client.CallContext(ctx, "MockService.SlowMethod", time.Millisecond, &response)
+
+		assert.NoError(t, errCall)
+		assert.Equal(t, MockMethodResponseValue, response)
+	})
+}
+
+func TestClient_GoContext(t *testing.T) {
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		var response int
+		doneChan := client.GoContext(ctx, "MockService.SlowMethod", time.Second, &response, nil)
+		<-doneChan.Done
+
+		var errTimeout common.MicronetTimeoutError
+		assert.True(t, errors.As(doneChan.Error, &errTimeout))
+	})
+
+	t.Run("Provided done channel", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+
+		done := make(chan *rpc.Call, 1)
+		var response int
+		call := client.GoContext(context.Background(), "MockService.MockMethod", true, &response, done)
+		result := <-done
+
+		assert.Same(t, call, result)
+		assert.NoError(t, result.Error)
+		assert.Equal(t, MockMethodResponseValue, response)
+	})
+}
+
+func TestClient_ConcurrentReconnection(t *testing.T) {
+	t.Run("Flapping server", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+
+		var serverConns []net.Conn
+		var serverConnMu sync.Mutex
+		accepted := 0
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				serverConnMu.Lock()
+				serverConns = append(serverConns, conn)
+				accepted++
+				serverConnMu.Unlock()
+				go mockServer.ServeConn(conn)
+			}
+		}()
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		client.SetRetryPolicy(ConstantBackoff{
+			RetryLimits: RetryLimits{MaxAttempts: 20},
+			Interval:    5 * time.Millisecond,
+		})
+
+		// ---- Drop every connection periodically ----
+		stopFlapping := make(chan struct{})
+		flapped := make(chan int)
+		go func() {
+			flaps := 0
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stopFlapping:
+					flapped <- flaps
+					return
+				case <-ticker.C:
+					serverConnMu.Lock()
+					for _, conn := range serverConns {
+						conn.Close()
+					}
+					serverConns = nil
+					serverConnMu.Unlock()
+					flaps++
+				}
+			}
+		}()
+		// ---------------------------------------------
+
+		var wg sync.WaitGroup
+		for g := 0; g < 50; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					var response int
+					if g%2 == 0 {
+						client.Call("MockService.MockMethod", true, &response)
+					} else {
+						call := client.Go("MockService.MockMethod", true, &response, nil)
+						<-call.Done
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		close(stopFlapping)
+		flaps := <-flapped
+
+		// every flap causes at most one shared redial
+		serverConnMu.Lock()
+		assert.LessOrEqual(t, accepted, flaps+2)
+		serverConnMu.Unlock()
+
+		var response int
+		errCall := client.Call("MockService.MockMethod", true, &response)
+		assert.NoError(t, errCall)
+		assert.Equal(t, MockMethodResponseValue, response)
+	})
+
+	t.Run("Closed client does not reconnect", func(t *testing.T) {
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go rpc.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		assert.NoError(t, client.Close())
+
+		var response int
+		errCall := client.Call("MockService.MockMethod", true, &response)
+		assert.ErrorIs(t, errCall, rpc.ErrShutdown)
+	})
+
+	t.Run("Lazy client connects on first call", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+
+		mockService := &MockService{}
+		err := mockServer.Register(mockService)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client := NewLazyClient(netConf)
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		var response int
+		errCall := client.Call("MockService.MockMethod", true, &response)
+		assert.NoError(t, errCall)
+		assert.Equal(t, MockMethodResponseValue, response)
+	})
+}
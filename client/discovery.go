@@ -0,0 +1,348 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"micronet/common"
+
+	"gopkg.in/yaml.v3"
+)
+
+/**
+ * The FileResolver reads the instances of the services from a JSON or YAML file, mapping names to NetConfs:
+ *
+ *	billing:
+ *	  - ip: 10.0.0.1
+ *	    port: "1234"
+ *
+ * The file is read again every interval, the watchers are notified of the services that changed
+ * A file that cannot be read or parsed is logged and the previous instances are kept
+ * An instance's Name defaults to its service's name, and its Protocol to tcp
+ */
+type FileResolver struct {
+	path     string
+	mutex    sync.Mutex
+	content  []byte
+	services map[string][]common.NetConf
+	watchers map[int]*resolverWatcher
+	nextId   int
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+/**
+ * The resolverWatcher is the callback of a Watch
+ */
+type resolverWatcher struct {
+	name   string
+	notify func([]common.NetConf)
+}
+
+/**
+ * NewFileResolver reads the file and watches it for changes until Close
+ * @param path is the file, parsed as YAML with a .yaml or .yml extension and as JSON otherwise
+ * @param interval is the delay between two reads of the file, the file is read once when it is not positive
+ * @return the initialized FileResolver, or the error reading or parsing the file
+ */
+func NewFileResolver(path string, interval time.Duration) (*FileResolver, error) {
+	r := &FileResolver{
+		path:     path,
+		watchers: make(map[int]*resolverWatcher),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	_, err := r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	go r.watch(interval)
+
+	return r, nil
+}
+
+/**
+ * Resolve lists the instances of a service read from the file
+ */
+func (r *FileResolver) Resolve(ctx context.Context, name string) ([]common.NetConf, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Clone(r.services[name]), nil
+}
+
+/**
+ * Watch calls notify with the new instances of a service each time the file changes them
+ * @return the function to stop watching
+ */
+func (r *FileResolver) Watch(name string, notify func([]common.NetConf)) func() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := r.nextId
+	r.nextId++
+	r.watchers[id] = &resolverWatcher{name: name, notify: notify}
+
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		delete(r.watchers, id)
+	}
+}
+
+/**
+ * Close stops watching the file
+ */
+func (r *FileResolver) Close() error {
+	r.mutex.Lock()
+	select {
+	case <-r.stop:
+		r.mutex.Unlock()
+		return fmt.Errorf("file resolver already closed")
+	default:
+		close(r.stop)
+	}
+	r.mutex.Unlock()
+
+	<-r.done
+
+	return nil
+}
+
+/**
+ * watch reloads the file every interval and notifies the watchers of the changed services, until Close
+ */
+func (r *FileResolver) watch(interval time.Duration) {
+	defer close(r.done)
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			changed, err := r.reload()
+			if err != nil {
+				log.Printf("reloading %s failed: %s", r.path, err)
+				continue
+			}
+
+			for _, watcher := range changed {
+				watcher()
+			}
+		}
+	}
+}
+
+/**
+ * reload reads the file again if its content changed
+ * @return the notifications of the watchers whose service changed
+ */
+func (r *FileResolver) reload() ([]func(), error) {
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	unchanged := r.content != nil && bytes.Equal(content, r.content)
+	r.mutex.Unlock()
+	if unchanged {
+		return nil, nil
+	}
+
+	services, err := parseServices(r.path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var changed []func()
+	for _, watcher := range r.watchers {
+		if !slices.Equal(r.services[watcher.name], services[watcher.name]) {
+			networks := slices.Clone(services[watcher.name])
+			changed = append(changed, func() { watcher.notify(networks) })
+		}
+	}
+	r.content = content
+	r.services = services
+
+	return changed, nil
+}
+
+/**
+ * parseServices decodes a file of services, as YAML or JSON following its extension
+ */
+func parseServices(path string, content []byte) (map[string][]common.NetConf, error) {
+	services := make(map[string][]common.NetConf)
+
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &services)
+	default:
+		err = json.Unmarshal(content, &services)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for name, networks := range services {
+		for i := range networks {
+			if networks[i].Name == "" {
+				networks[i].Name = name
+			}
+			if networks[i].Protocol == "" {
+				networks[i].Protocol = "tcp"
+			}
+		}
+	}
+
+	return services, nil
+}
+
+/**
+ * The EnvResolver reads the instances of a service from an environment variable
+ * The variable is the Prefix followed by the service's name in upper case, other characters than letters and
+ * digits being replaced by underscores: billing-api is MICRONET_BILLING_API with the MICRONET_ prefix
+ * Its value is a comma separated list of host:port addresses, called over tcp
+ */
+type EnvResolver struct {
+	Prefix string
+	Lookup func(key string) (string, bool)
+}
+
+/**
+ * NewEnvResolver creates an EnvResolver reading the process' environment
+ * @param prefix is the prefix of the variables
+ */
+func NewEnvResolver(prefix string) *EnvResolver {
+	return &EnvResolver{Prefix: prefix, Lookup: os.LookupEnv}
+}
+
+/**
+ * Resolve lists the addresses of the service's variable, none if it is not set
+ */
+func (r *EnvResolver) Resolve(ctx context.Context, name string) ([]common.NetConf, error) {
+	key := r.Key(name)
+	value, ok := r.Lookup(key)
+	if !ok {
+		return nil, nil
+	}
+
+	var networks []common.NetConf
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", key, err)
+		}
+		networks = append(networks, common.NetConf{Name: name, Ip: host, Port: port, Protocol: "tcp"})
+	}
+
+	return networks, nil
+}
+
+/**
+ * Key is the environment variable of a service
+ */
+func (r *EnvResolver) Key(name string) string {
+	return r.Prefix + strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return c
+		}
+		return '_'
+	}, name)
+}
+
+/**
+ * The LookupSRVFunc has the prototype of net.Resolver.LookupSRV
+ */
+type LookupSRVFunc func(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error)
+
+/**
+ * The DNSResolver finds the instances of a service in the SRV records _service._protocol.domain
+ * Only the records of the lowest priority are used, the other ones being backups
+ * LookupSRV can be replaced to resolve without a DNS server
+ */
+type DNSResolver struct {
+	Domain    string
+	Protocol  string
+	LookupSRV LookupSRVFunc
+}
+
+/**
+ * NewDNSResolver creates a DNSResolver using the system's DNS resolver over tcp
+ * @param domain is the domain of the SRV records
+ */
+func NewDNSResolver(domain string) *DNSResolver {
+	return &DNSResolver{Domain: domain, Protocol: "tcp", LookupSRV: net.DefaultResolver.LookupSRV}
+}
+
+/**
+ * Resolve lists the targets of the service's SRV records, none if there is no record
+ */
+func (r *DNSResolver) Resolve(ctx context.Context, name string) ([]common.NetConf, error) {
+	_, records, err := r.LookupSRV(ctx, name, r.Protocol, r.Domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []common.NetConf
+	for _, record := range records {
+		if record.Priority != lowestPriority(records) {
+			continue
+		}
+		networks = append(networks, common.NetConf{
+			Name:     name,
+			Ip:       strings.TrimSuffix(record.Target, "."),
+			Port:     strconv.Itoa(int(record.Port)),
+			Protocol: r.Protocol,
+		})
+	}
+
+	return networks, nil
+}
+
+func lowestPriority(records []*net.SRV) uint16 {
+	lowest := records[0].Priority
+	for _, record := range records {
+		lowest = min(lowest, record.Priority)
+	}
+
+	return lowest
+}
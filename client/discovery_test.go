@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileResolver(t *testing.T) {
+	t.Run("JSON and YAML files", func(t *testing.T) {
+		dir := t.TempDir()
+		files := map[string]string{
+			"services.json": `{"billing": [{"Ip": "10.0.0.1", "Port": "1234"}, {"ip": "10.0.0.2", "port": "1234", "codec": "jsonrpc"}]}`,
+			"services.yaml": "billing:\n  - ip: 10.0.0.1\n    port: 1234\n  - ip: 10.0.0.2\n    port: \"1234\"\n    codec: jsonrpc\n",
+		}
+
+		for file, content := range files {
+			path := filepath.Join(dir, file)
+			assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+			resolver, err := NewFileResolver(path, time.Minute)
+			if !assert.NoError(t, err, file) {
+				continue
+			}
+
+			networks, err := resolver.Resolve(context.Background(), "billing")
+			assert.NoError(t, err)
+			assert.Equal(t, []common.NetConf{
+				{Name: "billing", Ip: "10.0.0.1", Port: "1234", Protocol: "tcp"},
+				{Name: "billing", Ip: "10.0.0.2", Port: "1234", Protocol: "tcp", Codec: "jsonrpc"},
+			}, networks, file)
+
+			networks, err = resolver.Resolve(context.Background(), "shipping")
+			assert.NoError(t, err)
+			assert.Empty(t, networks)
+
+			assert.NoError(t, resolver.Close())
+		}
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "services.json")
+		assert.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+
+		_, err := NewFileResolver(path, time.Minute)
+		assert.Error(t, err)
+
+		_, err = NewFileResolver(filepath.Join(t.TempDir(), "missing.json"), time.Minute)
+		assert.Error(t, err)
+	})
+
+	t.Run("Hot reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "services.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte("billing:\n  - {ip: 10.0.0.1, port: \"1\"}\n"), 0o644))
+
+		resolver, err := NewFileResolver(path, 10*time.Millisecond)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer resolver.Close()
+
+		billing := make(chan []common.NetConf, 10)
+		shipping := make(chan []common.NetConf, 10)
+		resolver.Watch("billing", func(networks []common.NetConf) { billing <- networks })
+		stop := resolver.Watch("shipping", func(networks []common.NetConf) { shipping <- networks })
+
+		assert.NoError(t, os.WriteFile(path, []byte("billing:\n  - {ip: 10.0.0.2, port: \"1\"}\nshipping: []\n"), 0o644))
+		select {
+		case networks := <-billing:
+			assert.Equal(t, []common.NetConf{{Name: "billing", Ip: "10.0.0.2", Port: "1", Protocol: "tcp"}}, networks)
+		case <-time.After(time.Second):
+			t.Error("no change notified")
+		}
+
+		// a broken file keeps the previous instances
+		assert.NoError(t, os.WriteFile(path, []byte("billing: ["), 0o644))
+		time.Sleep(50 * time.Millisecond)
+		networks, _ := resolver.Resolve(context.Background(), "billing")
+		assert.Len(t, networks, 1)
+
+		stop()
+		assert.NoError(t, os.WriteFile(path, []byte("shipping:\n  - {ip: 10.0.0.3, port: \"1\"}\n"), 0o644))
+		assert.Eventually(t, func() bool {
+			networks, _ := resolver.Resolve(context.Background(), "shipping")
+			return len(networks) == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Empty(t, shipping)
+	})
+
+	t.Run("Zero interval does not watch", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "services.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"billing": [{"ip": "10.0.0.1", "port": "1"}]}`), 0o644))
+
+		resolver, err := NewFileResolver(path, 0)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		assert.NoError(t, os.WriteFile(path, []byte(`{"billing": []}`), 0o644))
+		time.Sleep(20 * time.Millisecond)
+		networks, _ := resolver.Resolve(context.Background(), "billing")
+		assert.Len(t, networks, 1)
+
+		assert.NoError(t, resolver.Close())
+	})
+}
+
+func TestEnvResolver(t *testing.T) {
+	resolver := NewEnvResolver("MICRONET_")
+	assert.Equal(t, "MICRONET_BILLING_API2", resolver.Key("billing-api2"))
+
+	t.Setenv("MICRONET_BILLING_API", " 10.0.0.1:1234, [::1]:1235,")
+	networks, err := resolver.Resolve(context.Background(), "billing-api")
+	assert.NoError(t, err)
+	assert.Equal(t, []common.NetConf{
+		{Name: "billing-api", Ip: "10.0.0.1", Port: "1234", Protocol: "tcp"},
+		{Name: "billing-api", Ip: "::1", Port: "1235", Protocol: "tcp"},
+	}, networks)
+
+	networks, err = resolver.Resolve(context.Background(), "shipping")
+	assert.NoError(t, err)
+	assert.Empty(t, networks)
+
+	resolver.Lookup = func(key string) (string, bool) { return "10.0.0.1", true }
+	_, err = resolver.Resolve(context.Background(), "billing")
+	assert.Error(t, err)
+}
+
+func TestDNSResolver(t *testing.T) {
+	resolver := NewDNSResolver("service.consul")
+	resolver.LookupSRV = func(ctx context.Context, service string, proto string, name string) (string, []*net.SRV, error) {
+		switch service {
+		case "billing":
+			assert.Equal(t, "tcp", proto)
+			assert.Equal(t, "service.consul", name)
+			return "", []*net.SRV{
+				{Target: "backup.service.consul.", Port: 1234, Priority: 20},
+				{Target: "a.service.consul.", Port: 1234, Priority: 10, Weight: 5},
+				{Target: "b.service.consul.", Port: 1235, Priority: 10, Weight: 5},
+			}, nil
+		case "shipping":
+			return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return "", nil, errors.New("dns server unreachable")
+	}
+
+	networks, err := resolver.Resolve(context.Background(), "billing")
+	assert.NoError(t, err)
+	assert.Equal(t, []common.NetConf{
+		{Name: "billing", Ip: "a.service.consul", Port: "1234", Protocol: "tcp"},
+		{Name: "billing", Ip: "b.service.consul", Port: "1235", Protocol: "tcp"},
+	}, networks)
+
+	networks, err = resolver.Resolve(context.Background(), "shipping")
+	assert.NoError(t, err)
+	assert.Empty(t, networks)
+
+	_, err = resolver.Resolve(context.Background(), "orders")
+	assert.Error(t, err)
+}
+
+func TestResolvedClient_Rebalance(t *testing.T) {
+	networks, _ := startReplicas(t, "12373", "12374")
+
+	path := filepath.Join(t.TempDir(), "services.json")
+	write := func(ports ...string) {
+		content := `{"replica": [`
+		for i, port := range ports {
+			if i > 0 {
+				content += ","
+			}
+			content += `{"Ip": "127.0.0.1", "Port": "` + port + `"}`
+		}
+		assert.NoError(t, os.WriteFile(path, []byte(content+"]}"), 0o644))
+	}
+	write("12373")
+
+	resolver, err := NewFileResolver(path, 10*time.Millisecond)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resolver.Close()
+
+	// the changes are followed without polling the resolver
+	conf := DefaultBalancerConf
+	conf.ResolveInterval = 0
+	balancer, err := NewResolvedClient(resolver, "replica", conf)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer balancer.Close()
+
+	assert.Equal(t, "12373", callName(t, balancer, ""))
+
+	write("12373", "12374")
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual(networks, balancer.Healthy())
+	}, time.Second, 10*time.Millisecond)
+
+	write("12374")
+	assert.Eventually(t, func() bool { return len(balancer.Healthy()) == 1 }, time.Second, 10*time.Millisecond)
+	for range 4 {
+		assert.Equal(t, "12374", callName(t, balancer, ""))
+	}
+
+	_, err = NewResolvedClient(resolver, "missing", conf)
+	assert.ErrorIs(t, err, common.MicronetUnknownServiceError{Name: "missing"})
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"micronet/codec"
+	"micronet/common"
+)
+
+// The time given to the remote server to answer the handshake
+const handshakeTimeout = 10 * time.Second
+
+/**
+ * negotiate sends the preamble requesting the remote's codec, or the server's choice if none is configured,
+ * and the remote's compression
+ * @param conn is the new connexion, before any request
+ * @return the connexion to use, compressed if negotiated, and the codec accepted by the server,
+ * or a common.MicronetHandshakeError
+ */
+func (c *Client) negotiate(conn net.Conn) (net.Conn, codec.Codec, error) {
+	if c.remote.Codec != "" {
+		if _, errCodec := codec.Lookup(c.remote.Codec); errCodec != nil {
+			return nil, nil, errCodec
+		}
+	}
+
+	var compressor codec.Compressor
+	if c.remote.Compression != "" {
+		var errCompression error
+		compressor, errCompression = codec.LookupCompressor(c.remote.Compression)
+		if errCompression != nil {
+			return nil, nil, errCompression
+		}
+	}
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	preamble := codec.Preamble{Version: codec.MaxVersion, Codec: c.remote.Codec, Compression: c.remote.Compression}
+	errWrite := codec.WritePreamble(conn, preamble)
+	if errWrite != nil {
+		return nil, nil, errWrite
+	}
+
+	answer, errRead := codec.ReadAnswer(conn)
+	if errRead != nil {
+		return nil, nil, common.MicronetHandshakeError{NetConf: c.remote, Reason: errRead.Error()}
+	}
+	if !answer.Accepted {
+		return nil, nil, common.MicronetHandshakeError{NetConf: c.remote, Reason: answer.Reason}
+	}
+	if !codec.SupportsVersion(answer.Version) {
+		reason := fmt.Sprintf("unsupported protocol version %d, client supports %d to %d", answer.Version, codec.MinVersion, codec.MaxVersion)
+		return nil, nil, common.MicronetHandshakeError{NetConf: c.remote, Reason: reason}
+	}
+	if answer.Compression != preamble.Compression {
+		reason := fmt.Sprintf("compression %q answered to a %q request", answer.Compression, preamble.Compression)
+		return nil, nil, common.MicronetHandshakeError{NetConf: c.remote, Reason: reason}
+	}
+
+	clientCodec, errCodec := codec.Lookup(answer.Codec)
+	if errCodec != nil {
+		return nil, nil, errCodec
+	}
+
+	if compressor != nil {
+		return codec.NewCompressedConn(conn, conn, compressor), clientCodec, nil
+	}
+
+	return conn, clientCodec, nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"micronet/codec"
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+ * startHandshakeListener accepts connexions, reads their preamble and replies the answer built by respond
+ * Accepted connexions are then served with the answered codec
+ */
+func startHandshakeListener(t *testing.T, port string, respond func(codec.Preamble) codec.Answer) common.NetConf {
+	listener, err := net.Listen("tcp", "127.0.0.1:"+port)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	srv := rpc.NewServer()
+	srv.Register(new(MockService))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			reader := bufio.NewReader(conn)
+			if !codec.IsPreamble(reader) {
+				conn.Close()
+				continue
+			}
+			preamble, _ := codec.ReadPreamble(reader)
+			answer := respond(preamble)
+			codec.WriteAnswer(conn, answer)
+			if !answer.Accepted {
+				conn.Close()
+				continue
+			}
+
+			serverCodec, _ := codec.Lookup(answer.Codec)
+			go srv.ServeCodec(serverCodec.NewServerCodec(&codec.BufferedConn{Conn: conn, Reader: reader}))
+		}
+	}()
+
+	return common.NetConf{Name: "handshake", Protocol: "tcp", Ip: "127.0.0.1", Port: port, Handshake: true}
+}
+
+func TestClient_Handshake(t *testing.T) {
+	t.Run("Client uses the codec chosen by the server", func(t *testing.T) {
+		remote := startHandshakeListener(t, "12353", func(preamble codec.Preamble) codec.Answer {
+			assert.Equal(t, "", preamble.Codec)
+			assert.Equal(t, codec.MaxVersion, preamble.Version)
+			return codec.Answer{Version: preamble.Version, Accepted: true, Codec: codec.MessagePack}
+		})
+
+		cli, err := NewClient(remote)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer cli.Close()
+
+		var resp int
+		assert.NoError(t, cli.Call("MockService.MockMethod", true, &resp))
+		assert.Equal(t, MockMethodResponseValue, resp)
+	})
+
+	t.Run("Refused handshake", func(t *testing.T) {
+		remote := startHandshakeListener(t, "12354", func(preamble codec.Preamble) codec.Answer {
+			return codec.Answer{Version: preamble.Version, Reason: "unsupported protocol version"}
+		})
+
+		_, err := NewClient(remote)
+		assert.Equal(t, common.MicronetHandshakeError{NetConf: remote, Reason: "unsupported protocol version"}, err)
+	})
+
+	t.Run("Incompatible server version", func(t *testing.T) {
+		remote := startHandshakeListener(t, "12355", func(preamble codec.Preamble) codec.Answer {
+			return codec.Answer{Version: codec.MaxVersion + 1, Accepted: true, Codec: codec.Gob}
+		})
+
+		_, err := NewClient(remote)
+		var errHandshake common.MicronetHandshakeError
+		if assert.ErrorAs(t, err, &errHandshake) {
+			assert.Contains(t, errHandshake.Reason, "unsupported protocol version")
+		}
+	})
+}
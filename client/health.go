@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"micronet/common"
+)
+
+// The wait of each Watch call, the watch is renewed after it
+const healthWatchTimeout = 30 * time.Second
+
+/**
+ * CheckHealth asks the Health service of the remote Server for the status of one of its services
+ * @param cli is the client of the remote Server
+ * @param service is a registered service's name, or "" for the Server
+ * @return the status, UNKNOWN if the service is neither registered nor set, or a network error
+ */
+func CheckHealth(ctx context.Context, cli I_Client, service string) (common.HealthStatus, error) {
+	res := common.HealthCheckResponse{}
+	err := cli.CallContext(ctx, "Health.Check", &common.HealthCheckRequest{Service: service}, &res)
+
+	return res.Status, err
+}
+
+/**
+ * WatchHealth calls notify with the status of a service of the remote Server, then with each of its changes
+ * It blocks until ctx is done or a call fails, a stopping Server being reported NOT_SERVING first
+ * @param cli is the client of the remote Server
+ * @param service is a registered service's name, or "" for the Server
+ * @param notify is called with every status, in order
+ * @return ctx's error or the error of the failed call
+ */
+func WatchHealth(ctx context.Context, cli I_Client, service string, notify func(common.HealthStatus)) error {
+	check := common.HealthCheckResponse{}
+	err := cli.CallContext(ctx, "Health.Check", &common.HealthCheckRequest{Service: service}, &check)
+	if err != nil {
+		return watchError(ctx, err)
+	}
+	notify(check.Status)
+
+	version := check.Version
+	for {
+		res := common.HealthWatchResponse{}
+		req := &common.HealthWatchRequest{Service: service, Version: version, Timeout: healthWatchTimeout}
+		err := cli.CallContext(ctx, "Health.Watch", req, &res)
+		if err != nil {
+			return watchError(ctx, err)
+		}
+
+		if res.Version != version {
+			version = res.Version
+			notify(res.Status)
+		}
+	}
+}
+
+func watchError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
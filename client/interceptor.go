@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+
+	"micronet/common"
+)
+
+/**
+ * The CallInfo describes an outgoing call running through the interceptors
+ * Args is the request as given to Call, Reply the response it fills
+ */
+type CallInfo struct {
+	ServiceMethod string
+	Args          any
+	Reply         any
+	Remote        common.NetConf
+}
+
+/**
+ * An Interceptor wraps every outgoing call of a Client, including its retries
+ * It calls next, possibly with a derived context, to run the following interceptors and the call,
+ * or returns an error to cancel the call
+ */
+type Interceptor func(ctx context.Context, info *CallInfo, next func(context.Context) error) error
+
+/**
+ * Use appends interceptors to the Client's chain, the first one used is the outermost
+ * The chain applies to Call, Go, CallContext, GoContext and Ping
+ * @param interceptors are run in order around every call
+ */
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptors...)
+}
+
+/**
+ * intercept runs a call through the current chain
+ * @param ctx is the call's context
+ * @param info describes the call
+ * @param invoker sends the call
+ * @return the error of an interceptor or of the call
+ */
+func (c *Client) intercept(ctx context.Context, info *CallInfo, invoker func(context.Context) error) error {
+	c.mutex.Lock()
+	interceptors := c.interceptors
+	c.mutex.Unlock()
+
+	return chain(ctx, interceptors, info, invoker)
+}
+
+func chain(ctx context.Context, interceptors []Interceptor, info *CallInfo, invoker func(context.Context) error) error {
+	if len(interceptors) == 0 {
+		return invoker(ctx)
+	}
+
+	return interceptors[0](ctx, info, func(ctx context.Context) error {
+		return chain(ctx, interceptors[1:], info, invoker)
+	})
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type requestIDKey struct{}
+
+func TestClient_Interceptors(t *testing.T) {
+	t.Run("Interceptors run in order around Call, Go and Ping", func(t *testing.T) {
+		mockServer := rpc.NewServer()
+		assert.NoError(t, mockServer.Register(new(MockService)))
+		assert.NoError(t, mockServer.Register(new(common.PingHandler)))
+
+		listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+		if errListen != nil {
+			t.Fatal(errListen)
+		}
+		defer listener.Close()
+		go mockServer.Accept(listener)
+		time.Sleep(ListenReadynessDuration)
+
+		client, errDial := NewClient(netConf)
+		assert.NoError(t, errDial)
+		defer client.Close()
+
+		var trace []string
+		record := func(name string) Interceptor {
+			return func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+				trace = append(trace, name+" "+info.ServiceMethod)
+				assert.Equal(t, netConf, info.Remote)
+				return next(ctx)
+			}
+		}
+		client.Use(record("outer"), record("inner"))
+
+		var resp int
+		assert.NoError(t, client.Call("MockService.MockMethod", true, &resp))
+		call := <-client.Go("MockService.MockMethod", true, &resp, nil).Done
+		assert.NoError(t, call.Error)
+		assert.NoError(t, client.Ping())
+
+		assert.Equal(t, []string{
+			"outer MockService.MockMethod", "inner MockService.MockMethod",
+			"outer MockService.MockMethod", "inner MockService.MockMethod",
+			"outer PingHandler.Ping", "inner PingHandler.Ping",
+		}, trace)
+	})
+
+	t.Run("Interceptor cancels the call", func(t *testing.T) {
+		client := NewLazyClient(netConf)
+		client.SetReconnectionConf(1, 0)
+		client.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+			return errors.New("forbidden")
+		})
+
+		var resp int
+		assert.EqualError(t, client.Call("MockService.MockMethod", true, &resp), "forbidden")
+	})
+}
+
+func TestMockClient_Interceptors(t *testing.T) {
+	t.Run("Interceptor derives the context given to the call", func(t *testing.T) {
+		mock := &MockClient{
+			CallContextFunc: func(ctx context.Context, serviceMethod string, args any, reply any) error {
+				assert.Equal(t, "42", ctx.Value(requestIDKey{}))
+				return nil
+			},
+		}
+		mock.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+			return next(context.WithValue(ctx, requestIDKey{}, "42"))
+		})
+
+		assert.NoError(t, mock.CallContext(context.Background(), "Service.Method", nil, nil))
+	})
+
+	t.Run("Interceptor observes failures", func(t *testing.T) {
+		var failures []string
+		mock := &MockClient{
+			CallFunc: func(serviceMethod string, args any, reply any) error {
+				return errors.New("unavailable")
+			},
+			PingFunc: func() error {
+				return nil
+			},
+		}
+		mock.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+			err := next(ctx)
+			if err != nil {
+				failures = append(failures, info.ServiceMethod+": "+err.Error())
+			}
+			return err
+		})
+
+		assert.Error(t, mock.Call("Service.Method", nil, nil))
+		assert.NoError(t, mock.Ping())
+		assert.Equal(t, []string{"Service.Method: unavailable"}, failures)
+	})
+
+	t.Run("Asynchronous calls are delivered on done", func(t *testing.T) {
+		mock := &MockClient{}
+		mock.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+			return errors.New("forbidden")
+		})
+
+		call := <-mock.Go("Service.Method", nil, nil, nil).Done
+		assert.EqualError(t, call.Error, "forbidden")
+
+		done := make(chan *rpc.Call, 1)
+		mock.GoContext(context.Background(), "Service.Method", nil, nil, done)
+		assert.EqualError(t, (<-done).Error, "forbidden")
+	})
+
+	t.Run("Interceptor sees the asynchronous error after completion", func(t *testing.T) {
+		var seen error
+		mock := &MockClient{
+			GoFunc: func(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+				call := &rpc.Call{ServiceMethod: serviceMethod, Done: done}
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					call.Error = errors.New("unavailable")
+					call.Done <- call
+				}()
+				return call
+			},
+		}
+		mock.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+			seen = next(ctx)
+			return seen
+		})
+
+		call := <-mock.Go("Service.Method", nil, nil, nil).Done
+		assert.EqualError(t, call.Error, "unavailable")
+		assert.EqualError(t, seen, "unavailable")
+	})
+}
@@ -1,15 +1,23 @@
 package client
 
 import (
+	"context"
+	"log"
 	"net/rpc"
 )
 
+/**
+ * The MockClient runs its calls through its interceptors, as a Client would, so they can be tested without a network
+ */
 type MockClient struct {
-	DialFunc  func() error
-	CallFunc  func(serviceMethod string, args any, reply any) error
-	GoFunc    func(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
-	CloseFunc func() error
-	PingFunc  func() error
+	Interceptors    []Interceptor
+	DialFunc        func() error
+	CallFunc        func(serviceMethod string, args any, reply any) error
+	GoFunc          func(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
+	CallContextFunc func(ctx context.Context, serviceMethod string, args any, reply any) error
+	GoContextFunc   func(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
+	CloseFunc       func() error
+	PingFunc        func() error
 }
 
 // Ensure MockClient implements I_Client
@@ -22,18 +30,80 @@ func (m *MockClient) Dial() error {
 	return nil
 }
 
+func (m *MockClient) Use(interceptors ...Interceptor) {
+	m.Interceptors = append(m.Interceptors, interceptors...)
+}
+
+func (m *MockClient) intercept(ctx context.Context, serviceMethod string, args any, reply any, invoker func(context.Context) error) error {
+	info := &CallInfo{ServiceMethod: serviceMethod, Args: args, Reply: reply}
+	return chain(ctx, m.Interceptors, info, invoker)
+}
+
 func (m *MockClient) Call(serviceMethod string, args any, reply any) error {
-	if m.CallFunc != nil {
-		return m.CallFunc(serviceMethod, args, reply)
-	}
-	return nil
+	return m.intercept(context.Background(), serviceMethod, args, reply, func(ctx context.Context) error {
+		if m.CallFunc != nil {
+			return m.CallFunc(serviceMethod, args, reply)
+		}
+		return nil
+	})
 }
 
 func (m *MockClient) Go(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
-	if m.GoFunc != nil {
-		return m.GoFunc(serviceMethod, args, reply, done)
+	return m.goCall(context.Background(), serviceMethod, args, reply, done, func(ctx context.Context, inner chan *rpc.Call) *rpc.Call {
+		if m.GoFunc != nil {
+			return m.GoFunc(serviceMethod, args, reply, inner)
+		}
+		return nil
+	})
+}
+
+func (m *MockClient) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	return m.intercept(ctx, serviceMethod, args, reply, func(ctx context.Context) error {
+		if m.CallContextFunc != nil {
+			return m.CallContextFunc(ctx, serviceMethod, args, reply)
+		}
+		return nil
+	})
+}
+
+func (m *MockClient) GoContext(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+	return m.goCall(ctx, serviceMethod, args, reply, done, func(ctx context.Context, inner chan *rpc.Call) *rpc.Call {
+		if m.GoContextFunc != nil {
+			return m.GoContextFunc(ctx, serviceMethod, args, reply, inner)
+		}
+		return nil
+	})
+}
+
+/**
+ * goCall runs the interceptors in a goroutine around the inner asynchronous call and waits for its completion,
+ * then delivers the call on done as Client.GoContext does
+ * @param invoker starts the inner call on the given channel, a nil call completes without error
+ */
+func (m *MockClient) goCall(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call, invoker func(context.Context, chan *rpc.Call) *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 1)
+	} else if cap(done) == 0 {
+		log.Panic("rpc: done channel is unbuffered")
 	}
-	return &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+
+	call := &rpc.Call{ServiceMethod: serviceMethod, Args: args, Reply: reply, Done: done}
+
+	go func() {
+		call.Error = m.intercept(ctx, serviceMethod, args, reply, func(ctx context.Context) error {
+			inner := invoker(ctx, make(chan *rpc.Call, 1))
+			if inner == nil {
+				return nil
+			}
+			if inner.Done != nil {
+				<-inner.Done
+			}
+			return inner.Error
+		})
+		call.Done <- call
+	}()
+
+	return call
 }
 
 func (m *MockClient) Close() error {
@@ -44,8 +114,10 @@ func (m *MockClient) Close() error {
 }
 
 func (m *MockClient) Ping() error {
-	if m.PingFunc != nil {
-		return m.PingFunc()
-	}
-	return nil
+	return m.intercept(context.Background(), "PingHandler.Ping", nil, nil, func(ctx context.Context) error {
+		if m.PingFunc != nil {
+			return m.PingFunc()
+		}
+		return nil
+	})
 }
@@ -0,0 +1,396 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"micronet/common"
+)
+
+/**
+ * DefaultPoolConf keeps between 1 and 8 connexions, closes the extra ones idle for a minute and pings every 10 seconds
+ */
+var DefaultPoolConf = PoolConf{
+	MinSize:        1,
+	MaxSize:        8,
+	IdleTimeout:    time.Minute,
+	HealthInterval: 10 * time.Second,
+}
+
+/**
+ * The PoolConf sizes a Pool and schedules its maintenance
+ * IdleTimeout closes the connexions unused for that long, down to MinSize, 0 never closes them
+ * HealthInterval pings the idle connexions and replaces the failing ones, 0 disables it
+ * Configure is applied to every new Client, to set its TLS config, retry policy or interceptors
+ */
+type PoolConf struct {
+	MinSize        int
+	MaxSize        int
+	IdleTimeout    time.Duration
+	HealthInterval time.Duration
+	Configure      func(*Client)
+}
+
+/**
+ * The Pool is an I_Client spreading calls over several connexions to the same remote Server,
+ * so that concurrent calls do not wait behind each other's replies
+ * Each call goes to the connexion with the fewest outstanding requests, a new connexion is opened
+ * when they are all busy and the pool is below MaxSize
+ */
+type Pool struct {
+	I_Client
+	remote  common.NetConf
+	conf    PoolConf
+	mutex   sync.Mutex
+	clients []*pooledClient
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+/**
+ * The pooledClient is a Client with its load
+ */
+type pooledClient struct {
+	*Client
+	outstanding atomic.Int64
+	lastUsed    atomic.Int64
+}
+
+/**
+ * NewPool creates a Pool and connects its MinSize first connexions
+ * @param network is the remote server to call
+ * @param conf sizes the pool
+ * @return the initialized Pool or the error of the first connexion
+ */
+func NewPool(network common.NetConf, conf PoolConf) (*Pool, error) {
+	conf.MinSize = max(conf.MinSize, 1)
+	conf.MaxSize = max(conf.MaxSize, conf.MinSize)
+
+	pool := &Pool{
+		remote: network,
+		conf:   conf,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	for range conf.MinSize {
+		pool.clients = append(pool.clients, pool.newClient())
+	}
+
+	errDial := pool.Dial()
+	if errDial != nil {
+		// the maintenance was not started
+		close(pool.done)
+		pool.Close()
+		return nil, errDial
+	}
+
+	go pool.maintain()
+
+	return pool, nil
+}
+
+func (p *Pool) newClient() *pooledClient {
+	cli := NewLazyClient(p.remote)
+	if p.conf.Configure != nil {
+		p.conf.Configure(cli)
+	}
+
+	pooled := &pooledClient{Client: cli}
+	pooled.lastUsed.Store(time.Now().UnixNano())
+
+	return pooled
+}
+
+/**
+ * Dial connects every connexion of the pool
+ * @return the first network error
+ */
+func (p *Pool) Dial() error {
+	p.mutex.Lock()
+	clients := append([]*pooledClient(nil), p.clients...)
+	p.mutex.Unlock()
+
+	var errs []error
+	for _, cli := range clients {
+		errs = append(errs, cli.Dial())
+	}
+
+	return errors.Join(errs...)
+}
+
+/**
+ * acquire selects the least loaded connexion, or opens a new one if they are all busy
+ */
+func (p *Pool) acquire() (*pooledClient, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil, rpc.ErrShutdown
+	}
+
+	var selected *pooledClient
+	for _, cli := range p.clients {
+		if selected == nil || cli.outstanding.Load() < selected.outstanding.Load() {
+			selected = cli
+		}
+	}
+
+	if selected == nil || (selected.outstanding.Load() > 0 && len(p.clients) < p.conf.MaxSize) {
+		selected = p.newClient()
+		p.clients = append(p.clients, selected)
+	}
+
+	selected.outstanding.Add(1)
+
+	return selected, nil
+}
+
+func (p *Pool) release(cli *pooledClient) {
+	cli.lastUsed.Store(time.Now().UnixNano())
+	cli.outstanding.Add(-1)
+}
+
+/**
+ * Call sends a synchronous request over the least loaded connexion
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param request is the derefenced request of any type
+ * @param response is the derefenced response of any type
+ * @return a potential network error
+ */
+func (p *Pool) Call(serviceMethod string, request any, response any) error {
+	return p.CallContext(context.Background(), serviceMethod, request, response)
+}
+
+/**
+ * Go sends an asynchronous request over the least loaded connexion
+ * See Client.Go()
+ */
+func (p *Pool) Go(serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
+	return p.GoContext(context.Background(), serviceMethod, request, response, done)
+}
+
+/**
+ * CallContext sends a synchronous request over the least loaded connexion, aborting when ctx is done
+ * See Client.CallContext()
+ */
+func (p *Pool) CallContext(ctx context.Context, serviceMethod string, request any, response any) error {
+	cli, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(cli)
+
+	return cli.CallContext(ctx, serviceMethod, request, response)
+}
+
+/**
+ * GoContext sends an asynchronous request over the least loaded connexion, aborting when ctx is done
+ * See Client.GoContext()
+ */
+func (p *Pool) GoContext(ctx context.Context, serviceMethod string, request any, response any, done chan *rpc.Call) *rpc.Call {
+	if done == nil {
+		done = make(chan *rpc.Call, 1)
+	} else if cap(done) == 0 {
+		log.Panic("rpc: done channel is unbuffered")
+	}
+
+	call := &rpc.Call{
+		ServiceMethod: serviceMethod,
+		Args:          request,
+		Reply:         response,
+		Done:          done,
+	}
+
+	go func() {
+		call.Error = p.CallContext(ctx, serviceMethod, request, response)
+		call.Done <- call
+	}()
+
+	return call
+}
+
+/**
+ * Ping tests the connexion to the server over the least loaded connexion
+ */
+func (p *Pool) Ping() error {
+	cli, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(cli)
+
+	return cli.Ping()
+}
+
+/**
+ * Close stops the maintenance, waiting for a running health check, and closes every connexion
+ */
+func (p *Pool) Close() error {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return fmt.Errorf("pool already closed")
+	}
+	p.closed = true
+	close(p.stop)
+	p.mutex.Unlock()
+
+	<-p.done
+
+	p.mutex.Lock()
+	clients := p.clients
+	p.clients = nil
+	p.mutex.Unlock()
+
+	for _, cli := range clients {
+		cli.Close()
+	}
+
+	return nil
+}
+
+/**
+ * Len is the number of connexions of the pool
+ */
+func (p *Pool) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.clients)
+}
+
+/**
+ * maintain periodically evicts the idle connexions and replaces the unhealthy ones, until the pool is closed
+ * done is closed once it returns
+ */
+func (p *Pool) maintain() {
+	defer close(p.done)
+
+	interval := p.conf.HealthInterval
+	if p.conf.IdleTimeout > 0 && (interval <= 0 || p.conf.IdleTimeout/2 < interval) {
+		interval = p.conf.IdleTimeout / 2
+	}
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastCheck := time.Now()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.evictIdle(now)
+			if p.conf.HealthInterval > 0 && now.Sub(lastCheck) >= p.conf.HealthInterval {
+				lastCheck = now
+				p.checkHealth()
+			}
+		}
+	}
+}
+
+/**
+ * evictIdle closes the connexions unused for IdleTimeout, keeping MinSize of them
+ */
+func (p *Pool) evictIdle(now time.Time) {
+	if p.conf.IdleTimeout <= 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	var evicted []*pooledClient
+	kept := p.clients[:0]
+	for _, cli := range p.clients {
+		idle := now.Sub(time.Unix(0, cli.lastUsed.Load())) >= p.conf.IdleTimeout
+		if idle && cli.outstanding.Load() == 0 && len(p.clients)-len(evicted) > p.conf.MinSize {
+			evicted = append(evicted, cli)
+			continue
+		}
+		kept = append(kept, cli)
+	}
+	p.clients = kept
+	p.mutex.Unlock()
+
+	for _, cli := range evicted {
+		cli.Close()
+	}
+}
+
+/**
+ * checkHealth pings the idle connexions and replaces the ones that fail, keeping MinSize connexions
+ * The pings do not go through the RetryPolicy, a failed connexion is redialed once
+ */
+func (p *Pool) checkHealth() {
+	p.mutex.Lock()
+	var idle []*pooledClient
+	for _, cli := range p.clients {
+		if cli.outstanding.Load() == 0 {
+			idle = append(idle, cli)
+		}
+	}
+	p.mutex.Unlock()
+
+	var unhealthy []*pooledClient
+	for _, cli := range idle {
+		ctx, cancel := context.WithTimeout(context.Background(), p.conf.HealthInterval)
+		errPing := cli.probe(ctx)
+		cancel()
+		if errPing != nil {
+			unhealthy = append(unhealthy, cli)
+		}
+	}
+	if len(unhealthy) == 0 {
+		return
+	}
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	var removed, added []*pooledClient
+	for _, cli := range unhealthy {
+		index := p.indexOf(cli)
+		if index < 0 || cli.outstanding.Load() > 0 {
+			continue
+		}
+		p.clients = append(p.clients[:index], p.clients[index+1:]...)
+		removed = append(removed, cli)
+	}
+	for len(p.clients) < p.conf.MinSize {
+		cli := p.newClient()
+		p.clients = append(p.clients, cli)
+		added = append(added, cli)
+	}
+	p.mutex.Unlock()
+
+	for _, cli := range removed {
+		log.Printf("Replacing unhealthy connexion to %+v", p.remote)
+		cli.Close()
+	}
+	for _, cli := range added {
+		cli.Dial()
+	}
+}
+
+func (p *Pool) indexOf(cli *pooledClient) int {
+	for i, pooled := range p.clients {
+		if pooled == cli {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+ * startCountingServer serves MockService and the ping handler, counting the accepted connexions
+ * @return the remote's config, the accepted connexions and a function closing the listener and connexions
+ */
+func startCountingServer(t *testing.T, port string) (common.NetConf, *atomic.Int32, func()) {
+	mockServer := rpc.NewServer()
+	mockServer.Register(new(MockService))
+	mockServer.Register(new(common.PingHandler))
+
+	listener, errListen := net.Listen("tcp", "127.0.0.1:"+port)
+	if errListen != nil {
+		t.Fatal(errListen)
+	}
+
+	var mutex sync.Mutex
+	var conns []net.Conn
+	accepted := new(atomic.Int32)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+			go mockServer.ServeConn(conn)
+		}
+	}()
+
+	kill := func() {
+		listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+	t.Cleanup(kill)
+
+	return common.NetConf{Name: "pool", Protocol: "tcp", Ip: "127.0.0.1", Port: port}, accepted, kill
+}
+
+func TestPool(t *testing.T) {
+	t.Run("Concurrent calls are spread over new connexions", func(t *testing.T) {
+		remote, accepted, _ := startCountingServer(t, "12356")
+
+		pool, err := NewPool(remote, PoolConf{MinSize: 1, MaxSize: 4})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer pool.Close()
+
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var resp int
+				assert.NoError(t, pool.Call("MockService.SlowMethod", 200*time.Millisecond, &resp))
+			}()
+		}
+		wg.Wait()
+
+		assert.Less(t, time.Since(start), 400*time.Millisecond)
+		assert.Equal(t, 4, pool.Len())
+		assert.Equal(t, int32(4), accepted.Load())
+	})
+
+	t.Run("Idle connexions are reused", func(t *testing.T) {
+		remote, accepted, _ := startCountingServer(t, "12357")
+
+		pool, err := NewPool(remote, PoolConf{MinSize: 1, MaxSize: 4})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer pool.Close()
+
+		for range 5 {
+			var resp int
+			assert.NoError(t, pool.Call("MockService.MockMethod", true, &resp))
+			assert.NoError(t, pool.Ping())
+		}
+		call := <-pool.Go("MockService.MockMethod", true, new(int), nil).Done
+		assert.NoError(t, call.Error)
+
+		assert.Equal(t, 1, pool.Len())
+		assert.Equal(t, int32(1), accepted.Load())
+	})
+
+	t.Run("Idle connexions are evicted down to MinSize", func(t *testing.T) {
+		remote, _, _ := startCountingServer(t, "12358")
+
+		pool, err := NewPool(remote, PoolConf{MinSize: 1, MaxSize: 3, IdleTimeout: 50 * time.Millisecond})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer pool.Close()
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pool.Call("MockService.SlowMethod", 100*time.Millisecond, new(int))
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 3, pool.Len())
+
+		assert.Eventually(t, func() bool { return pool.Len() == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Unhealthy connexions are replaced", func(t *testing.T) {
+		remote, _, kill := startCountingServer(t, "12359")
+
+		pool, err := NewPool(remote, PoolConf{
+			MinSize:        2,
+			MaxSize:        2,
+			HealthInterval: 100 * time.Millisecond,
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer pool.Close()
+
+		pool.mutex.Lock()
+		original := append([]*pooledClient(nil), pool.clients...)
+		pool.mutex.Unlock()
+
+		kill()
+		assert.Eventually(t, func() bool {
+			pool.mutex.Lock()
+			defer pool.mutex.Unlock()
+			return pool.indexOf(original[0]) < 0 && pool.indexOf(original[1]) < 0
+		}, 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 2, pool.Len())
+
+		startCountingServer(t, "12359")
+
+		var resp int
+		assert.NoError(t, pool.Call("MockService.MockMethod", true, &resp))
+	})
+
+	t.Run("Health checks bypass the interceptors and the RetryPolicy", func(t *testing.T) {
+		remote, accepted, _ := startCountingServer(t, "12375")
+
+		var calls atomic.Int32
+		pool, err := NewPool(remote, PoolConf{
+			MinSize:        1,
+			MaxSize:        1,
+			HealthInterval: 20 * time.Millisecond,
+			Configure: func(cli *Client) {
+				cli.SetReconnectionConf(-1, time.Second)
+				cli.Use(func(ctx context.Context, info *CallInfo, next func(context.Context) error) error {
+					calls.Add(1)
+					return next(ctx)
+				})
+			},
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer pool.Close()
+
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, int32(0), calls.Load())
+		assert.Equal(t, int32(1), accepted.Load())
+	})
+
+	t.Run("Closed pool", func(t *testing.T) {
+		remote, _, _ := startCountingServer(t, "12360")
+
+		pool, err := NewPool(remote, DefaultPoolConf)
+		assert.NoError(t, err)
+		assert.NoError(t, pool.Close())
+		select {
+		case <-pool.done:
+		default:
+			t.Error("Close returned before the maintenance stopped")
+		}
+
+		assert.ErrorIs(t, pool.Call("MockService.MockMethod", true, new(int)), rpc.ErrShutdown)
+		assert.Error(t, pool.Close())
+	})
+
+	t.Run("Unreachable remote", func(t *testing.T) {
+		_, err := NewPool(common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "12361"}, DefaultPoolConf)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"micronet/common"
+)
+
+// The wait between two registration attempts of an instance registering in the background
+const registerRetryInterval = time.Second
+
+/**
+ * The Registration keeps an instance listed by a registry Server, renewing its lease by heartbeat
+ * The instance registers again if the registry lost its lease, after a restart for instance
+ * The embedded Client connects to the registry, set its TLS config or retry policy before Start
+ */
+type Registration struct {
+	*Client
+	instance   common.ServiceInstance
+	ttl        time.Duration
+	mutex      sync.Mutex
+	cancel     context.CancelFunc
+	done       chan struct{}
+	registered atomic.Bool
+}
+
+/**
+ * NewRegistration creates a Registration without registering the instance
+ * @param registry is the registry Server's network config
+ * @param instance is the NetConf of the registered Server, its Name is the service's name
+ * @param ttl is the requested lease, the registry's default when 0
+ */
+func NewRegistration(registry common.NetConf, instance common.ServiceInstance, ttl time.Duration) *Registration {
+	return &Registration{Client: NewLazyClient(registry), instance: instance, ttl: ttl}
+}
+
+/**
+ * Start registers the instance and renews its lease in the background until Stop
+ * @return a potential registration error, the lease is not renewed then
+ */
+func (r *Registration) Start() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel != nil {
+		return fmt.Errorf("registration of %s already started", r.instance.NetConf.Name)
+	}
+
+	ttl, err := r.register(context.Background())
+	if err != nil {
+		return err
+	}
+	r.registered.Store(true)
+
+	r.run(ttl)
+
+	return nil
+}
+
+/**
+ * StartBackground registers the instance in the background, retrying every second until the registry answers,
+ * then renews its lease until Stop
+ * @return an error if the Registration was already started
+ */
+func (r *Registration) StartBackground() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel != nil {
+		return fmt.Errorf("registration of %s already started", r.instance.NetConf.Name)
+	}
+
+	r.run(0)
+
+	return nil
+}
+
+/**
+ * run starts keeping the instance registered, the mutex must be held
+ */
+func (r *Registration) run(ttl time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel, r.done = cancel, make(chan struct{})
+	go r.keep(ctx, ttl)
+}
+
+/**
+ * Stop renews the lease no more, deregisters the instance and closes the connexion to the registry
+ * @return a potential deregistration error, the lease then expires on its own
+ */
+func (r *Registration) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	return r.StopContext(ctx)
+}
+
+/**
+ * StopContext renews the lease no more, deregisters the instance, if it registered, and closes the connexion to the registry
+ * @param ctx bounds the deregistration
+ * @return a potential deregistration error, the lease then expires on its own
+ */
+func (r *Registration) StopContext(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cancel == nil {
+		return fmt.Errorf("registration of %s not started", r.instance.NetConf.Name)
+	}
+	r.cancel()
+	<-r.done
+	r.cancel, r.done = nil, nil
+	defer r.Close()
+
+	if !r.registered.Load() {
+		return nil
+	}
+
+	return r.CallContext(ctx, "Registry.Deregister", &common.DeregisterRequest{NetConf: r.instance.NetConf}, &common.DeregisterResponse{})
+}
+
+/**
+ * register sends the instance to the registry
+ * @return the lease granted by the registry
+ */
+func (r *Registration) register(ctx context.Context) (time.Duration, error) {
+	res := common.RegisterResponse{}
+	err := r.CallContext(ctx, "Registry.Register", &common.RegisterRequest{Instance: r.instance, TTL: r.ttl}, &res)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.TTL, nil
+}
+
+/**
+ * keep registers the instance if it is not yet, then heartbeats every third of its lease until ctx is done
+ * It registers again when the lease was lost
+ * @param ttl is the lease granted by a previous registration, 0 if the instance is not registered
+ */
+func (r *Registration) keep(ctx context.Context, ttl time.Duration) {
+	defer close(r.done)
+
+	timer := time.NewTimer(max(ttl/3, 0))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if !r.registered.Load() {
+			callCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+			granted, err := r.register(callCtx)
+			cancel()
+			if err != nil && ctx.Err() != nil {
+				// stopped during the call, the registry may have listed the instance
+				r.registered.Store(true)
+				return
+			}
+			if err != nil {
+				log.Printf("registering %+v failed, retrying in %s: %s", r.instance.NetConf, registerRetryInterval, err)
+				timer.Reset(registerRetryInterval)
+				continue
+			}
+			log.Printf("registered %+v", r.instance.NetConf)
+			r.registered.Store(true)
+			ttl = granted
+		} else {
+			callCtx, cancel := context.WithTimeout(ctx, max(ttl/3, time.Millisecond))
+			err := r.CallContext(callCtx, "Registry.Heartbeat", &common.HeartbeatRequest{NetConf: r.instance.NetConf}, &common.HeartbeatResponse{})
+			if common.CodeOf(err) == common.NotFound {
+				log.Printf("lease of %+v lost, registering again", r.instance.NetConf)
+				_, err = r.register(callCtx)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("renewing the lease of %+v failed: %s", r.instance.NetConf, err)
+			}
+		}
+
+		timer.Reset(max(ttl/3, time.Millisecond))
+	}
+}
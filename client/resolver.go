@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"micronet/common"
+)
+
+// The longest wait for a Resolver to answer
+const resolveTimeout = 5 * time.Second
+
+/**
+ * The Resolver finds the live instances of a service by name
+ */
+type Resolver interface {
+	Resolve(ctx context.Context, name string) ([]common.NetConf, error)
+}
+
+/**
+ * The WatchedResolver reports the changes of a service's instances as they happen
+ * A BalancedClient created by NewResolvedClient watches its service, on top of resolving it every ResolveInterval
+ */
+type WatchedResolver interface {
+	Resolver
+	Watch(name string, notify func([]common.NetConf)) (stop func())
+}
+
+var (
+	defaultResolverMutex sync.Mutex
+	defaultResolver      Resolver
+)
+
+/**
+ * SetDefaultResolver sets the Resolver used by NewClientByName
+ */
+func SetDefaultResolver(resolver Resolver) {
+	defaultResolverMutex.Lock()
+	defer defaultResolverMutex.Unlock()
+
+	defaultResolver = resolver
+}
+
+/**
+ * NewClientByName creates a BalancedClient following the instances of a service, found by the default Resolver
+ * See SetDefaultResolver() and NewResolvedClient()
+ * @param name is the NetConf.Name of the service's instances
+ */
+func NewClientByName(name string) (*BalancedClient, error) {
+	defaultResolverMutex.Lock()
+	resolver := defaultResolver
+	defaultResolverMutex.Unlock()
+
+	if resolver == nil {
+		return nil, fmt.Errorf("no default resolver to find %s, see SetDefaultResolver", name)
+	}
+
+	return NewResolvedClient(resolver, name, DefaultBalancerConf)
+}
+
+/**
+ * NewResolvedClient creates a BalancedClient over the instances of a service
+ * The instances are resolved again every conf.ResolveInterval, and as soon as a WatchedResolver reports a change,
+ * the client then balances over the new ones and drops the ones that are gone
+ * @param resolver finds the instances
+ * @param name is the NetConf.Name of the service's instances
+ * @param conf is the balancing strategy, health checking and resolution interval
+ * @return the initialized BalancedClient, or a common.MicronetUnknownServiceError if the service has no instance
+ */
+func NewResolvedClient(resolver Resolver, name string, conf BalancerConf) (*BalancedClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	networks, err := resolver.Resolve(ctx, name)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if len(networks) == 0 {
+		return nil, common.MicronetUnknownServiceError{Name: name}
+	}
+
+	balancer, err := NewBalancedClient(networks, conf)
+	if err != nil {
+		return nil, err
+	}
+	balancer.resolver = resolver
+	balancer.name = name
+
+	if watched, ok := resolver.(WatchedResolver); ok {
+		unwatch := watched.Watch(name, balancer.SetEndpoints)
+		balancer.mutex.Lock()
+		balancer.unwatch = unwatch
+		balancer.mutex.Unlock()
+	}
+
+	if conf.ResolveInterval > 0 {
+		go balancer.refresh()
+	}
+
+	return balancer, nil
+}
+
+/**
+ * refresh resolves the endpoints every ResolveInterval until the client is closed
+ * The endpoints are kept when the resolution fails
+ */
+func (b *BalancedClient) refresh() {
+	ticker := time.NewTicker(b.conf.ResolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), min(b.conf.ResolveInterval, resolveTimeout))
+			networks, err := b.resolver.Resolve(ctx, b.name)
+			cancel()
+			if err != nil {
+				log.Printf("resolving %s failed: %s", b.name, err)
+				continue
+			}
+
+			b.SetEndpoints(networks)
+		}
+	}
+}
+
+/**
+ * The RegistryResolver resolves the instances listed by a registry Server
+ * The embedded Client connects to the registry, set its TLS config or retry policy before the first Resolve
+ */
+type RegistryResolver struct {
+	*Client
+}
+
+/**
+ * NewRegistryResolver creates a RegistryResolver, connecting to the registry on the first Resolve
+ * @param registry is the registry Server's network config
+ */
+func NewRegistryResolver(registry common.NetConf) *RegistryResolver {
+	return &RegistryResolver{Client: NewLazyClient(registry)}
+}
+
+/**
+ * Resolve asks the registry for the live instances of a service
+ */
+func (r *RegistryResolver) Resolve(ctx context.Context, name string) ([]common.NetConf, error) {
+	res := common.ResolveResponse{}
+	err := r.CallContext(ctx, "Registry.Resolve", &common.ResolveRequest{Name: name}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := make([]common.NetConf, 0, len(res.Instances))
+	for _, instance := range res.Instances {
+		networks = append(networks, instance.NetConf)
+	}
+
+	return networks, nil
+}
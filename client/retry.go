@@ -0,0 +1,198 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/rpc"
+	"time"
+
+	"micronet/common"
+)
+
+/**
+ * DefaultRetryPolicy makes 3 reconnection attempts one second apart
+ */
+var DefaultRetryPolicy RetryPolicy = ConstantBackoff{
+	RetryLimits: RetryLimits{MaxAttempts: 3},
+	Interval:    time.Second,
+}
+
+/**
+ * The RetryPolicy drives the reconnection of a Client after a failed call
+ */
+type RetryPolicy interface {
+	/**
+	 * Next computes the delay to wait before a reconnection attempt
+	 * @param attempt is the attempt number, starting at 1
+	 * @param previous is the delay returned for the previous attempt, 0 for the first one
+	 * @param elapsed is the time spent reconnecting so far
+	 * @return the delay, and false if no more attempts should be made
+	 */
+	Next(attempt int, previous time.Duration, elapsed time.Duration) (time.Duration, bool)
+
+	/**
+	 * Retryable tells if a failed call may be retried on a new connection
+	 * @param err is the call's error
+	 */
+	Retryable(err error) bool
+}
+
+/**
+ * The RetryLimits bound a RetryPolicy and decide which errors are retryable
+ * MaxAttempts is the number of reconnection attempts: 0, the zero value, disables the reconnection and a negative value means no limit
+ * MaxElapsed is the total time given to the reconnection, 0 means no limit
+ * RetryIf overrides IsRetryable when set
+ */
+type RetryLimits struct {
+	MaxAttempts int
+	MaxElapsed  time.Duration
+	RetryIf     func(error) bool
+}
+
+/**
+ * Retryable tells if a failed call may be retried on a new connection
+ * @param err is the call's error
+ */
+func (l RetryLimits) Retryable(err error) bool {
+	if l.RetryIf != nil {
+		return l.RetryIf(err)
+	}
+
+	return IsRetryable(err)
+}
+
+/**
+ * bound applies the limits to the delay computed by a policy
+ */
+func (l RetryLimits) bound(attempt int, delay time.Duration, elapsed time.Duration) (time.Duration, bool) {
+	if l.MaxAttempts >= 0 && attempt > l.MaxAttempts {
+		return 0, false
+	}
+
+	if l.MaxElapsed > 0 {
+		if elapsed >= l.MaxElapsed {
+			return 0, false
+		}
+		delay = min(delay, l.MaxElapsed-elapsed)
+	}
+
+	return delay, true
+}
+
+/**
+ * The ConstantBackoff waits the same Interval between reconnection attempts
+ */
+type ConstantBackoff struct {
+	RetryLimits
+	Interval time.Duration
+}
+
+func (b ConstantBackoff) Next(attempt int, previous time.Duration, elapsed time.Duration) (time.Duration, bool) {
+	if attempt <= 1 {
+		return b.bound(attempt, 0, elapsed)
+	}
+
+	return b.bound(attempt, b.Interval, elapsed)
+}
+
+/**
+ * The ExponentialBackoff multiplies the delay by Multiplier after each attempt, starting at Initial and capped at Max
+ * A Multiplier below 1 defaults to 2, a zero Max means no cap
+ * Like every policy, it does not reconnect unless RetryLimits.MaxAttempts is set
+ */
+type ExponentialBackoff struct {
+	RetryLimits
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+func (b ExponentialBackoff) Next(attempt int, previous time.Duration, elapsed time.Duration) (time.Duration, bool) {
+	if attempt <= 1 {
+		return b.bound(attempt, 0, elapsed)
+	}
+
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt-2))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	return b.bound(attempt, time.Duration(delay), elapsed)
+}
+
+/**
+ * The DecorrelatedJitterBackoff picks a random delay between Base and three times the previous delay, capped at Cap
+ * It spreads the reconnections of many clients losing the same server
+ */
+type DecorrelatedJitterBackoff struct {
+	RetryLimits
+	Base time.Duration
+	Cap  time.Duration
+}
+
+func (b DecorrelatedJitterBackoff) Next(attempt int, previous time.Duration, elapsed time.Duration) (time.Duration, bool) {
+	if attempt <= 1 {
+		return b.bound(attempt, 0, elapsed)
+	}
+
+	previous = max(previous, b.Base)
+	delay := b.Base
+	if spread := 3*previous - b.Base; spread > 0 {
+		delay += rand.N(spread)
+	}
+	if b.Cap > 0 {
+		delay = min(delay, b.Cap)
+	}
+
+	return b.bound(attempt, delay, elapsed)
+}
+
+// The origins of the Unavailable statuses that are retried: the request was refused before reaching its handler
+var retryableOrigins = map[string]bool{
+	fmt.Sprintf("%T", common.MicronetShutdownError{}): true,
+}
+
+/**
+ * IsRetryable is the default retry predicate, based on the common.Code of the error
+ * An error without Status is retried if it is a connection failure
+ * An Unavailable Status is retried only if its origin is the shutdown of the remote Server, never when it comes
+ * from the remote handler, as re-sending the request could duplicate its effects: use RetryLimits.RetryIf to retry them
+ * The other codes are never retried
+ * @param err is the call's error
+ */
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch common.CodeOf(err) {
+	case common.Unavailable:
+		status, _ := common.StatusOf(err)
+		return retryableOrigins[status.Origin()]
+	case common.Unknown:
+		// without Status, the error comes from the connection or is a plain error of the handler
+	default:
+		return false
+	}
+
+	var errServer rpc.ServerError
+	if errors.As(err, &errServer) {
+		return false
+	}
+
+	if errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var errNet net.Error
+	return errors.As(err, &errNet)
+}
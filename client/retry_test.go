@@ -0,0 +1,226 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry_ConstantBackoff(t *testing.T) {
+	policy := ConstantBackoff{RetryLimits: RetryLimits{MaxAttempts: 3}, Interval: 10 * time.Millisecond}
+
+	delay, ok := policy.Next(1, 0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	delay, ok = policy.Next(3, delay, 0)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, delay)
+
+	_, ok = policy.Next(4, delay, 0)
+	assert.False(t, ok)
+}
+
+func TestRetry_ExponentialBackoff(t *testing.T) {
+	policy := ExponentialBackoff{
+		RetryLimits: RetryLimits{MaxAttempts: -1},
+		Initial:     10 * time.Millisecond,
+		Max:         50 * time.Millisecond,
+	}
+
+	expected := []time.Duration{0, 10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, want := range expected {
+		delay, ok := policy.Next(i+1, 0, 0)
+		assert.True(t, ok)
+		assert.Equal(t, want, delay)
+	}
+}
+
+func TestRetry_DecorrelatedJitterBackoff(t *testing.T) {
+	policy := DecorrelatedJitterBackoff{
+		RetryLimits: RetryLimits{MaxAttempts: -1},
+		Base:        10 * time.Millisecond,
+		Cap:         100 * time.Millisecond,
+	}
+
+	var delay time.Duration
+	for attempt := 2; attempt < 50; attempt++ {
+		previous := delay
+		var ok bool
+		delay, ok = policy.Next(attempt, previous, 0)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, policy.Base)
+		assert.LessOrEqual(t, delay, policy.Cap)
+		assert.LessOrEqual(t, delay, 3*max(previous, policy.Base))
+	}
+}
+
+func TestRetry_ZeroMaxAttempts(t *testing.T) {
+	policy := ExponentialBackoff{Initial: 10 * time.Millisecond}
+
+	_, ok := policy.Next(1, 0, 0)
+	assert.False(t, ok)
+}
+
+func TestRetry_MaxElapsed(t *testing.T) {
+	policy := ConstantBackoff{
+		RetryLimits: RetryLimits{MaxAttempts: -1, MaxElapsed: 100 * time.Millisecond},
+		Interval:    80 * time.Millisecond,
+	}
+
+	delay, ok := policy.Next(2, 0, 50*time.Millisecond)
+	assert.True(t, ok)
+	assert.Equal(t, 50*time.Millisecond, delay)
+
+	_, ok = policy.Next(3, delay, 100*time.Millisecond)
+	assert.False(t, ok)
+}
+
+func TestRetry_IsRetryable(t *testing.T) {
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(rpc.ServerError("not found")))
+	assert.False(t, IsRetryable(errors.New("reading body")))
+	assert.True(t, IsRetryable(rpc.ErrShutdown))
+	assert.True(t, IsRetryable(io.ErrUnexpectedEOF))
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", &net.OpError{Op: "read", Err: errors.New("reset")})))
+
+	assert.False(t, IsRetryable(common.NewStatus(common.Unavailable, "overloaded")))
+	assert.True(t, IsRetryable(common.MicronetShutdownError{}))
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", common.MicronetShutdownError{})))
+	assert.True(t, IsRetryable(common.DecodeError(rpc.ServerError(common.EncodeError(common.MicronetShutdownError{})))))
+	assert.False(t, IsRetryable(common.MicronetCircuitOpenError{}))
+	assert.False(t, IsRetryable(common.NewStatus(common.Unavailable, "overloaded").WithDetail("micronet-error", "common.MicronetCircuitOpenError")))
+	assert.False(t, IsRetryable(common.NewStatus(common.Unknown, "plain")))
+	assert.False(t, IsRetryable(common.NewStatus(common.NotFound, "no such user")))
+	assert.False(t, IsRetryable(fmt.Errorf("wrapped: %w", common.NewStatus(common.Internal, "bug"))))
+
+	limits := RetryLimits{RetryIf: func(error) bool { return true }}
+	assert.True(t, limits.Retryable(rpc.ServerError("not found")))
+}
+
+type FailingService struct{}
+
+func (s *FailingService) Fail(req bool, resp *int) error {
+	return errors.New("application error")
+}
+
+func TestRetry_ApplicationErrorIsNotRetried(t *testing.T) {
+	mockServer := rpc.NewServer()
+	err := mockServer.Register(&FailingService{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+	if errListen != nil {
+		t.Fatal(errListen)
+	}
+	defer listener.Close()
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go mockServer.ServeConn(conn)
+		}
+	}()
+	time.Sleep(ListenReadynessDuration)
+
+	client, errDial := NewClient(netConf)
+	assert.NoError(t, errDial)
+
+	var response int
+	errCall := client.Call("FailingService.Fail", true, &response)
+
+	assert.EqualError(t, errCall, "application error")
+	assert.Equal(t, int32(1), accepted.Load())
+}
+
+type StatusService struct {
+	calls atomic.Int32
+}
+
+func (s *StatusService) NotFound(req bool, resp *int) error {
+	s.calls.Add(1)
+	status := common.NewStatus(common.NotFound, "no such user").WithDetail("user", "42")
+	return errors.New(common.EncodeError(status))
+}
+
+func (s *StatusService) Unavailable(req bool, resp *int) error {
+	s.calls.Add(1)
+	return errors.New(common.EncodeError(common.NewStatus(common.Unavailable, "overloaded")))
+}
+
+func (s *StatusService) ShuttingDown(req bool, resp *int) error {
+	if s.calls.Add(1) == 1 {
+		return errors.New(common.EncodeError(common.MicronetShutdownError{}))
+	}
+	return nil
+}
+
+func TestRetry_StatusCodes(t *testing.T) {
+	service := &StatusService{}
+	mockServer := rpc.NewServer()
+	err := mockServer.Register(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, errListen := net.Listen(netConf.Protocol, netConf.Ip+":"+netConf.Port)
+	if errListen != nil {
+		t.Fatal(errListen)
+	}
+	defer listener.Close()
+	go mockServer.Accept(listener)
+	time.Sleep(ListenReadynessDuration)
+
+	client, errDial := NewClient(netConf)
+	assert.NoError(t, errDial)
+	client.SetReconnectionConf(3, 0)
+	defer client.Close()
+
+	t.Run("Status is reconstructed and not retried", func(t *testing.T) {
+		var response int
+		errCall := client.Call("StatusService.NotFound", true, &response)
+
+		var status *common.Status
+		if assert.ErrorAs(t, errCall, &status) {
+			assert.Equal(t, common.NotFound, status.Code)
+			assert.Equal(t, "no such user", status.Message)
+			assert.Equal(t, map[string]string{"user": "42"}, status.Details)
+		}
+		assert.Equal(t, common.NotFound, common.CodeOf(errCall))
+		assert.Equal(t, int32(1), service.calls.Load())
+	})
+
+	t.Run("Unavailable status of the handler is not retried", func(t *testing.T) {
+		service.calls.Store(0)
+
+		var response int
+		errCall := client.Call("StatusService.Unavailable", true, &response)
+
+		assert.Equal(t, common.Unavailable, common.CodeOf(errCall))
+		assert.Equal(t, int32(1), service.calls.Load())
+	})
+
+	t.Run("Shutdown of the server is retried", func(t *testing.T) {
+		service.calls.Store(0)
+
+		var response int
+		assert.NoError(t, client.Call("StatusService.ShuttingDown", true, &response))
+		assert.Equal(t, int32(2), service.calls.Load())
+	})
+}
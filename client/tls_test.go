@@ -0,0 +1,70 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"micronet/common"
+	"micronet/internal/testcert"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startTLSListener(t *testing.T, port string, config *tls.Config) common.NetConf {
+	listener, err := tls.Listen("tcp", "127.0.0.1:"+port, config)
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	srv := rpc.NewServer()
+	srv.Register(new(MockService))
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.ServeConn(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	return common.NetConf{Name: "tls", Protocol: "tcp", Ip: host, Port: port}
+}
+
+func TestClient_TLS(t *testing.T) {
+	authority := testcert.NewAuthority(t)
+
+	t.Run("Mutual TLS call succeeds", func(t *testing.T) {
+		remote := startTLSListener(t, "12350", authority.ServerConfig(t))
+
+		cli, err := NewTLSClient(remote, authority.ClientConfig(t, "client"))
+		assert.NoError(t, err)
+		defer cli.Close()
+
+		var resp int
+		assert.NoError(t, cli.Call("MockService.MockMethod", true, &resp))
+		assert.Equal(t, MockMethodResponseValue, resp)
+	})
+
+	t.Run("Untrusted server is rejected", func(t *testing.T) {
+		remote := startTLSListener(t, "12351", authority.ServerConfig(t))
+
+		config := testcert.NewAuthority(t).ClientConfig(t, "client")
+		_, err := NewTLSClient(remote, config)
+		assert.Error(t, err)
+	})
+
+	t.Run("Lazy client reconnects over TLS", func(t *testing.T) {
+		remote := startTLSListener(t, "12352", authority.ServerConfig(t))
+
+		cli := NewLazyClient(remote)
+		cli.SetTLSConfig(authority.ClientConfig(t, "client"))
+		defer cli.Close()
+
+		var resp int
+		assert.NoError(t, cli.Call("MockService.MockMethod", true, &resp))
+		assert.Equal(t, MockMethodResponseValue, resp)
+	})
+}
@@ -1,6 +1,8 @@
 package clientServer
 
 import (
+	"context"
+	"crypto/tls"
 	"net/rpc"
 
 	"micronet/client"
@@ -27,15 +29,13 @@ type ClientServer struct {
 
 /**
  * NewClientServer creates a ClientServer, inheriting from Client and Server
+ * The client connects to the remote on its first call, so that both peers can be created in any order
  * @param selfNetwork is the server's network config
  * @param remoteNetwork is the remote server's network config
  * @return the initialized ClientServer or error
  */
 func NewClientServer(selfNetwork common.NetConf, remoteNetwork common.NetConf) (*ClientServer, error) {
-	cli, errDial := client.NewClient(remoteNetwork)
-	if errDial != nil {
-		return nil, errDial
-	}
+	cli := client.NewLazyClient(remoteNetwork)
 
 	srv, errListen := server.NewServer(selfNetwork)
 	if errListen != nil {
@@ -45,6 +45,36 @@ func NewClientServer(selfNetwork common.NetConf, remoteNetwork common.NetConf) (
 	return &ClientServer{Client: cli, Server: srv}, nil
 }
 
+/**
+ * NewTLSClientServer creates a ClientServer secured by TLS on both sides, before its client's first connexion
+ * @param selfNetwork is the server's network config
+ * @param remoteNetwork is the remote server's network config
+ * @param serverConfig is the TLS configuration of the server
+ * @param clientConfig is the TLS configuration to call the remote with, add a client certificate for mutual TLS
+ * @return the initialized ClientServer or error
+ */
+func NewTLSClientServer(selfNetwork common.NetConf, remoteNetwork common.NetConf, serverConfig *tls.Config, clientConfig *tls.Config) (*ClientServer, error) {
+	clientServer, err := NewClientServer(selfNetwork, remoteNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	clientServer.SetTLSConfig(serverConfig, clientConfig)
+
+	return clientServer, nil
+}
+
+/**
+ * SetTLSConfig secures both sides of the ClientServer, nil configs keep plain TCP
+ * Must be called before Start() and before the first call, the client uses TLS from its first connexion
+ * @param serverConfig is the TLS configuration of the server
+ * @param clientConfig is the TLS configuration to call the remote with
+ */
+func (s *ClientServer) SetTLSConfig(serverConfig *tls.Config, clientConfig *tls.Config) {
+	s.Server.SetTLSConfig(serverConfig)
+	s.Client.SetTLSConfig(clientConfig)
+}
+
 /**
  * Register any additional handler
  * @param rcvr any structure that implements at leaste one handler prototyped function
@@ -96,6 +126,31 @@ func (c *ClientServer) Go(serviceMethod string, args any, reply any, done chan *
 	return c.Client.Go(serviceMethod, args, reply, done)
 }
 
+/**
+ * CallContext sends a synchronous request to the remote Server, aborting when ctx is done
+ * @param ctx bounds the call
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param args is the derefenced request of any type
+ * @param reply is the derefenced response of any type
+ * @return a potential network or timeout error
+ */
+func (c *ClientServer) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	return c.Client.CallContext(ctx, serviceMethod, args, reply)
+}
+
+/**
+ * GoContext sends a asynchronous request to the remote Server, aborting when ctx is done
+ * @param ctx bounds the call
+ * @param serviceMethod is the remote's "handler.function" to call
+ * @param args is the derefenced request of any type
+ * @param reply is the derefenced response of any type
+ * @param done channel will signal when the call is complete by returning the same Call object
+ * @return the done channel
+ */
+func (c *ClientServer) GoContext(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+	return c.Client.GoContext(ctx, serviceMethod, args, reply, done)
+}
+
 /**
  * Stop the running server
  * Same as Close()
@@ -106,6 +161,21 @@ func (c *ClientServer) Stop() error {
 	return err
 }
 
+/**
+ * Shutdown gracefully stops the running server, then closes the client
+ * @param ctx bounds the time given to in-flight calls
+ * @return ctx's error if in-flight calls did not complete in time, or a client closing error
+ */
+func (c *ClientServer) Shutdown(ctx context.Context) error {
+	errShutdown := c.Server.Shutdown(ctx)
+	errClose := c.Client.Close()
+	if errShutdown != nil {
+		return errShutdown
+	}
+
+	return errClose
+}
+
 /**
  * Close the running server
  * Same as Stop()
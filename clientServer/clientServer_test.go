@@ -2,6 +2,7 @@ package clientServer
 
 import (
 	"testing"
+	"time"
 
 	"micronet/common"
 )
@@ -20,27 +21,29 @@ func TestClientServerPing(t *testing.T) {
 	}
 	var err error
 
+	// the peers are created before any of them listens, their clients connect lazily
 	srv1, err := NewClientServer(netConf1, netConf2)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	go func() {
-		err = srv1.Start()
-		if err != nil {
-			t.Error(err)
+		errStart := srv1.Start()
+		if errStart != nil {
+			t.Error(errStart)
 		}
 	}()
 
 	srv2, err := NewClientServer(netConf2, netConf1)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	go func() {
-		err = srv2.Start()
-		if err != nil {
-			t.Error(err)
+		errStart := srv2.Start()
+		if errStart != nil {
+			t.Error(errStart)
 		}
 	}()
+	time.Sleep(100 * time.Millisecond)
 
 	err = srv1.Dial()
 	if err != nil {
@@ -0,0 +1,173 @@
+package clientServer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"micronet/client"
+	"micronet/codec"
+	"micronet/common"
+	"micronet/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Greeting struct {
+	Name string
+}
+
+type GreeterService struct{}
+
+func (s *GreeterService) Greet(req *Greeting, res *Greeting) error {
+	res.Name = "hello " + req.Name
+	return nil
+}
+
+func (s *GreeterService) Missing(req *Greeting, res *Greeting) error {
+	return common.Errorf(common.NotFound, "no greeting for %s", req.Name)
+}
+
+func (s *GreeterService) Lookup(req *Greeting, res *Greeting) error {
+	return common.MicronetUnknownServiceError{Name: req.Name}
+}
+
+func TestCodecMatrix(t *testing.T) {
+	for i, name := range []string{codec.Gob, codec.JSONRPC, codec.MessagePack} {
+		t.Run(name+" Client and Server", func(t *testing.T) {
+			netConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: strconv.Itoa(17100 + 3*i), Codec: name}
+
+			srv, err := server.NewServer(netConf)
+			assert.NoError(t, err)
+			assert.NoError(t, srv.Register(new(GreeterService)))
+			go srv.Start()
+			defer srv.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			cli, err := client.NewClient(netConf)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer cli.Close()
+
+			assert.NoError(t, cli.Ping())
+
+			var res Greeting
+			assert.NoError(t, cli.Call("GreeterService.Greet", &Greeting{Name: name}, &res))
+			assert.Equal(t, "hello "+name, res.Name)
+
+			var status *common.Status
+			if assert.ErrorAs(t, cli.Call("GreeterService.Missing", &Greeting{Name: name}, &res), &status) {
+				assert.Equal(t, common.NotFound, status.Code)
+			}
+
+			var errUnknown common.MicronetUnknownServiceError
+			if assert.ErrorAs(t, cli.Call("GreeterService.Lookup", &Greeting{Name: name}, &res), &errUnknown) {
+				assert.Equal(t, name, errUnknown.Name)
+			}
+		})
+
+		t.Run(name+" ClientServer", func(t *testing.T) {
+			netConf1 := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: strconv.Itoa(17101 + 3*i), Codec: name}
+			netConf2 := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: strconv.Itoa(17102 + 3*i), Codec: name}
+
+			remote, err := server.NewServer(netConf1)
+			assert.NoError(t, err)
+			assert.NoError(t, remote.Register(new(GreeterService)))
+			go remote.Start()
+			defer remote.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			self, err := NewClientServer(netConf2, netConf1)
+			assert.NoError(t, err)
+			assert.NoError(t, self.Register(new(GreeterService)))
+			go self.Start()
+			defer self.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			assert.NoError(t, self.Ping())
+
+			cli, err := client.NewClient(netConf2)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer cli.Close()
+			assert.NoError(t, cli.Ping())
+
+			var res Greeting
+			assert.NoError(t, self.Call("GreeterService.Greet", &Greeting{Name: "peer"}, &res))
+			assert.Equal(t, "hello peer", res.Name)
+		})
+	}
+
+	t.Run("Negotiated codecs", func(t *testing.T) {
+		netConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17110", Codec: codec.MessagePack}
+
+		srv, err := server.NewServer(netConf)
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Register(new(GreeterService)))
+		go srv.Start()
+		defer srv.Stop()
+		time.Sleep(100 * time.Millisecond)
+
+		for _, requested := range []string{"", codec.Gob, codec.JSONRPC, codec.MessagePack} {
+			remote := netConf
+			remote.Codec = requested
+			remote.Handshake = true
+
+			cli, err := client.NewClient(remote)
+			if !assert.NoError(t, err) {
+				continue
+			}
+
+			var res Greeting
+			assert.NoError(t, cli.Call("GreeterService.Greet", &Greeting{Name: requested}, &res))
+			assert.Equal(t, "hello "+requested, res.Name)
+			cli.Close()
+		}
+	})
+
+	t.Run("Compressed connexions", func(t *testing.T) {
+		netConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17111"}
+
+		srv, err := server.NewServer(netConf)
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Register(new(GreeterService)))
+		go srv.Start()
+		defer srv.Stop()
+		time.Sleep(100 * time.Millisecond)
+
+		for _, compression := range []string{codec.Gzip, codec.Flate} {
+			remote := netConf
+			remote.Compression = compression
+
+			cli, err := client.NewClient(remote)
+			if !assert.NoError(t, err) {
+				continue
+			}
+
+			var res Greeting
+			for range 3 {
+				assert.NoError(t, cli.Call("GreeterService.Greet", &Greeting{Name: compression}, &res))
+				assert.Equal(t, "hello "+compression, res.Name)
+			}
+			cli.Close()
+		}
+
+		remote := netConf
+		remote.Compression = "zstd"
+		_, err = client.NewClient(remote)
+		assert.Equal(t, common.MicronetUnknownCompressionError{Compression: "zstd"}, err)
+	})
+
+	t.Run("Unknown codec", func(t *testing.T) {
+		netConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17199", Codec: "xml"}
+
+		srv, err := server.NewServer(netConf)
+		assert.NoError(t, err)
+		assert.Equal(t, common.MicronetUnknownCodecError{Codec: "xml"}, srv.Start())
+
+		_, err = client.NewClient(netConf)
+		assert.Equal(t, common.MicronetUnknownCodecError{Codec: "xml"}, err)
+	})
+}
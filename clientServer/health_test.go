@@ -0,0 +1,75 @@
+package clientServer
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"micronet/client"
+	"micronet/codec"
+	"micronet/common"
+	"micronet/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receiveStatus(t *testing.T, statuses chan common.HealthStatus) common.HealthStatus {
+	select {
+	case status := <-statuses:
+		return status
+	case <-time.After(time.Second):
+		t.Error("no status recieved")
+		return common.HealthUnknown
+	}
+}
+
+func TestHealthWatch(t *testing.T) {
+	for i, name := range []string{codec.Gob, codec.JSONRPC, codec.MessagePack} {
+		t.Run(name, func(t *testing.T) {
+			remoteConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: strconv.Itoa(17120 + 2*i), Codec: name}
+			selfConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: strconv.Itoa(17121 + 2*i), Codec: name}
+
+			remote, err := server.NewServer(remoteConf)
+			assert.NoError(t, err)
+			assert.NoError(t, remote.Register(new(GreeterService)))
+			go remote.Start()
+			defer remote.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			self, err := NewClientServer(selfConf, remoteConf)
+			assert.NoError(t, err)
+			go self.Start()
+			defer self.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			status, err := client.CheckHealth(context.Background(), self.Client, "GreeterService")
+			assert.NoError(t, err)
+			assert.Equal(t, common.HealthServing, status)
+
+			statuses := make(chan common.HealthStatus, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+			watching := make(chan error, 1)
+			go func() {
+				watching <- client.WatchHealth(ctx, self.Client, "", func(status common.HealthStatus) { statuses <- status })
+			}()
+
+			assert.Equal(t, common.HealthServing, receiveStatus(t, statuses))
+
+			// the database is down, the server is up
+			remote.SetServingStatus("", common.HealthNotServing)
+			assert.Equal(t, common.HealthNotServing, receiveStatus(t, statuses))
+			remote.SetServingStatus("", common.HealthServing)
+			assert.Equal(t, common.HealthServing, receiveStatus(t, statuses))
+
+			cancel()
+			select {
+			case err := <-watching:
+				assert.ErrorIs(t, err, context.Canceled)
+			case <-time.After(time.Second):
+				t.Error("watch not stopped")
+			}
+			assert.Empty(t, statuses)
+		})
+	}
+}
@@ -1,22 +1,26 @@
 package clientServer
 
 import (
+	"context"
 	"net/rpc"
 )
 
 // MockClientServer implements I_ClientServer for unit testing.
 type MockClientServer struct {
 	// ----- CLIENT methods -----
-	DialFunc  func() error
-	CallFunc  func(serviceMethod string, args any, reply any) error
-	GoFunc    func(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
-	CloseFunc func() error
-	PingFunc  func() error
+	DialFunc        func() error
+	CallFunc        func(serviceMethod string, args any, reply any) error
+	GoFunc          func(serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
+	CallContextFunc func(ctx context.Context, serviceMethod string, args any, reply any) error
+	GoContextFunc   func(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call
+	CloseFunc       func() error
+	PingFunc        func() error
 
 	// ----- SERVER methods -----
 	RegisterFunc func(rcvr any) error
 	StartFunc    func() error
 	StopFunc     func()
+	ShutdownFunc func(ctx context.Context) error
 }
 
 // Ensure interface compliance.
@@ -50,6 +54,25 @@ func (m *MockClientServer) Go(serviceMethod string, args any, reply any, done ch
 	}
 }
 
+func (m *MockClientServer) CallContext(ctx context.Context, serviceMethod string, args any, reply any) error {
+	if m.CallContextFunc != nil {
+		return m.CallContextFunc(ctx, serviceMethod, args, reply)
+	}
+	return nil
+}
+
+func (m *MockClientServer) GoContext(ctx context.Context, serviceMethod string, args any, reply any, done chan *rpc.Call) *rpc.Call {
+	if m.GoContextFunc != nil {
+		return m.GoContextFunc(ctx, serviceMethod, args, reply, done)
+	}
+	return &rpc.Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         reply,
+		Done:          done,
+	}
+}
+
 func (m *MockClientServer) Close() error {
 	if m.CloseFunc != nil {
 		return m.CloseFunc()
@@ -85,3 +108,10 @@ func (m *MockClientServer) Stop() {
 		m.StopFunc()
 	}
 }
+
+func (m *MockClientServer) Shutdown(ctx context.Context) error {
+	if m.ShutdownFunc != nil {
+		return m.ShutdownFunc(ctx)
+	}
+	return nil
+}
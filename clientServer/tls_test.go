@@ -0,0 +1,49 @@
+package clientServer
+
+import (
+	"testing"
+	"time"
+
+	"micronet/common"
+	"micronet/internal/testcert"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientServerTLS(t *testing.T) {
+	authority := testcert.NewAuthority(t)
+	netConf1 := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17130"}
+	netConf2 := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17131"}
+
+	// the peers are created before any of them listens, TLS applies to their first connexion
+	srv1, err := NewTLSClientServer(netConf1, netConf2, authority.ServerConfig(t), authority.ClientConfig(t, "srv1"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	srv2, err := NewTLSClientServer(netConf2, netConf1, authority.ServerConfig(t), authority.ClientConfig(t, "srv2"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	go srv1.Start()
+	go srv2.Start()
+	defer srv1.Stop()
+	defer srv2.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	t.Run("Peers call each other over mutual TLS", func(t *testing.T) {
+		assert.NoError(t, srv1.Ping())
+		assert.NoError(t, srv2.Ping())
+	})
+
+	t.Run("Plain peer is refused", func(t *testing.T) {
+		plain, err := NewClientServer(common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "17132"}, netConf1)
+		assert.NoError(t, err)
+		plain.SetReconnectionConf(0, 0)
+		defer plain.Close()
+
+		// the plain connexion is accepted, its requests fail the TLS handshake
+		assert.NoError(t, plain.Dial())
+		assert.Error(t, plain.Ping())
+	})
+}
@@ -0,0 +1,66 @@
+package codec
+
+import (
+	"io"
+	"net/rpc"
+	"sync"
+
+	"micronet/common"
+)
+
+// The names of the codecs registered by default, the empty name is gob
+const (
+	Gob         = "gob"
+	JSONRPC     = "jsonrpc"
+	MessagePack = "msgpack"
+)
+
+/**
+ * A Codec creates the net/rpc codecs of a serialization format, for both ends of a connexion
+ */
+type Codec interface {
+	NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec
+	NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec
+}
+
+var (
+	mutex  sync.RWMutex
+	codecs = map[string]Codec{
+		Gob:         gobCodec{},
+		JSONRPC:     jsonCodec{},
+		MessagePack: msgpackCodec{},
+	}
+)
+
+/**
+ * Register makes a Codec selectable by name in NetConf.Codec, replacing any codec of the same name
+ * @param name is the value of NetConf.Codec selecting the codec
+ * @param codec creates the codecs of a connexion
+ */
+func Register(name string, codec Codec) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	codecs[name] = codec
+}
+
+/**
+ * Lookup finds a registered Codec
+ * @param name is the codec's name, gob if empty
+ * @return the Codec or a common.MicronetUnknownCodecError
+ */
+func Lookup(name string) (Codec, error) {
+	if name == "" {
+		name = Gob
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	codec, found := codecs[name]
+	if !found {
+		return nil, common.MicronetUnknownCodecError{Codec: name}
+	}
+
+	return codec, nil
+}
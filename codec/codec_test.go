@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Pair struct {
+	Name  string
+	Value int
+}
+
+type PairService struct{}
+
+func (s *PairService) Swap(req *Pair, res *Pair) error {
+	res.Name = req.Name + "!"
+	res.Value = -req.Value
+	return nil
+}
+
+func (s *PairService) Fail(req *Pair, res *Pair) error {
+	return errors.New("failed " + req.Name)
+}
+
+/**
+ * connect serves PairService over an in-memory connexion using the codec
+ */
+func connect(t *testing.T, codec Codec) *rpc.Client {
+	serverConn, clientConn := net.Pipe()
+
+	srv := rpc.NewServer()
+	assert.NoError(t, srv.Register(new(PairService)))
+	go srv.ServeCodec(codec.NewServerCodec(serverConn))
+
+	cli := rpc.NewClientWithCodec(codec.NewClientCodec(clientConn))
+	t.Cleanup(func() { cli.Close() })
+
+	return cli
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, name := range []string{Gob, JSONRPC, MessagePack} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := Lookup(name)
+			assert.NoError(t, err)
+			cli := connect(t, codec)
+
+			var res Pair
+			assert.NoError(t, cli.Call("PairService.Swap", &Pair{Name: "answer", Value: 42}, &res))
+			assert.Equal(t, Pair{Name: "answer!", Value: -42}, res)
+
+			assert.EqualError(t, cli.Call("PairService.Fail", &Pair{Name: "call"}, &res), "failed call")
+			assert.Error(t, cli.Call("PairService.Missing", &Pair{}, &res))
+
+			assert.NoError(t, cli.Call("PairService.Swap", &Pair{Name: "again", Value: 1}, &res))
+			assert.Equal(t, Pair{Name: "again!", Value: -1}, res)
+		})
+	}
+}
+
+type renamedCodec struct {
+	Codec
+}
+
+func TestCodec_Registry(t *testing.T) {
+	t.Run("Empty name is gob", func(t *testing.T) {
+		codec, err := Lookup("")
+		assert.NoError(t, err)
+		assert.Equal(t, gobCodec{}, codec)
+	})
+
+	t.Run("Unknown codec", func(t *testing.T) {
+		_, err := Lookup("xml")
+		assert.Equal(t, common.MicronetUnknownCodecError{Codec: "xml"}, err)
+	})
+
+	t.Run("Registered codec", func(t *testing.T) {
+		Register("renamed", renamedCodec{Codec: gobCodec{}})
+
+		codec, err := Lookup("renamed")
+		assert.NoError(t, err)
+		cli := connect(t, codec)
+
+		var res Pair
+		assert.NoError(t, cli.Call("PairService.Swap", &Pair{Name: "custom"}, &res))
+		assert.Equal(t, "custom!", res.Name)
+	})
+}
@@ -0,0 +1,138 @@
+package codec
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"sync"
+
+	"micronet/common"
+)
+
+// The names of the compressors registered by default
+const (
+	Gzip  = "gzip"
+	Flate = "flate"
+)
+
+/**
+ * The FlushWriter is a compressing writer that can flush what it compressed so far
+ */
+type FlushWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+/**
+ * A Compressor creates the compressing and decompressing streams of a connexion
+ */
+type Compressor interface {
+	NewWriter(w io.Writer) FlushWriter
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var compressors = map[string]Compressor{
+	Gzip:  gzipCompressor{},
+	Flate: flateCompressor{},
+}
+
+/**
+ * RegisterCompressor makes a Compressor selectable by name in NetConf.Compression, replacing any compressor of the same name
+ * @param name is the value of NetConf.Compression selecting the compressor
+ * @param compressor creates the streams of a connexion
+ */
+func RegisterCompressor(name string, compressor Compressor) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	compressors[name] = compressor
+}
+
+/**
+ * LookupCompressor finds a registered Compressor
+ * @param name is the compressor's name
+ * @return the Compressor or a common.MicronetUnknownCompressionError
+ */
+func LookupCompressor(name string) (Compressor, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	compressor, found := compressors[name]
+	if !found {
+		return nil, common.MicronetUnknownCompressionError{Compression: name}
+	}
+
+	return compressor, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) NewWriter(w io.Writer) FlushWriter {
+	return gzip.NewWriter(w)
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type flateCompressor struct{}
+
+func (flateCompressor) NewWriter(w io.Writer) FlushWriter {
+	writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+	return writer
+}
+
+func (flateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+/**
+ * The CompressedConn compresses a connexion in both directions
+ * Every Write is flushed, so that each message sent by a codec reaches the peer without waiting for the next one
+ * The decompressing reader is created on the first Read, as it may wait for the peer's stream header
+ */
+type CompressedConn struct {
+	net.Conn
+	compressor Compressor
+	source     io.Reader
+	writer     FlushWriter
+	reader     io.ReadCloser
+	readOnce   sync.Once
+	errReader  error
+}
+
+/**
+ * NewCompressedConn wraps a connexion once its handshake is done
+ * @param conn is the connexion to write to
+ * @param source is what to read from, the connexion or a reader buffering it
+ * @param compressor creates the streams
+ */
+func NewCompressedConn(conn net.Conn, source io.Reader, compressor Compressor) *CompressedConn {
+	return &CompressedConn{
+		Conn:       conn,
+		compressor: compressor,
+		source:     source,
+		writer:     compressor.NewWriter(conn),
+	}
+}
+
+func (c *CompressedConn) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	return n, c.writer.Flush()
+}
+
+func (c *CompressedConn) Read(p []byte) (int, error) {
+	c.readOnce.Do(func() {
+		c.reader, c.errReader = c.compressor.NewReader(c.source)
+	})
+	if c.errReader != nil {
+		return 0, c.errReader
+	}
+
+	return c.reader.Read(p)
+}
@@ -0,0 +1,166 @@
+package codec
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+ * connectCompressed serves PairService over an in-memory compressed connexion
+ */
+func connectCompressed(t testing.TB, codec Codec, compressor Compressor, wire *atomic.Int64) *rpc.Client {
+	serverConn, clientConn := net.Pipe()
+	counted := &countingConn{Conn: clientConn, written: wire}
+
+	srv := rpc.NewServer()
+	srv.Register(new(PairService))
+	srv.Register(new(EventService))
+	go srv.ServeCodec(codec.NewServerCodec(NewCompressedConn(serverConn, serverConn, compressor)))
+
+	cli := rpc.NewClientWithCodec(codec.NewClientCodec(NewCompressedConn(counted, counted, compressor)))
+	t.Cleanup(func() { cli.Close() })
+
+	return cli
+}
+
+/**
+ * The countingConn counts the bytes written on the wire
+ */
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if c.written != nil {
+		c.written.Add(int64(n))
+	}
+	return n, err
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	for _, compression := range []string{Gzip, Flate} {
+		for _, name := range []string{Gob, JSONRPC, MessagePack} {
+			t.Run(compression+" "+name, func(t *testing.T) {
+				codec, _ := Lookup(name)
+				compressor, err := LookupCompressor(compression)
+				assert.NoError(t, err)
+				cli := connectCompressed(t, codec, compressor, nil)
+
+				for i := range 3 {
+					var res Pair
+					assert.NoError(t, cli.Call("PairService.Swap", &Pair{Name: "compressed", Value: i}, &res))
+					assert.Equal(t, Pair{Name: "compressed!", Value: -i}, res)
+				}
+				assert.EqualError(t, cli.Call("PairService.Fail", &Pair{Name: "call"}, &Pair{}), "failed call")
+			})
+		}
+	}
+}
+
+func TestCompression_Registry(t *testing.T) {
+	_, err := LookupCompressor("zstd")
+	assert.Equal(t, common.MicronetUnknownCompressionError{Compression: "zstd"}, err)
+
+	RegisterCompressor("fast", flateCompressor{})
+	compressor, err := LookupCompressor("fast")
+	assert.NoError(t, err)
+	assert.Equal(t, flateCompressor{}, compressor)
+}
+
+/**
+ * The OrderEvent is a typical published message, with repetitive field names and values
+ */
+type OrderEvent struct {
+	OrderId   string
+	Customer  string
+	Country   string
+	Status    string
+	Items     []OrderItem
+	CreatedAt time.Time
+}
+
+type OrderItem struct {
+	Sku      string
+	Label    string
+	Quantity int
+	Price    float64
+}
+
+type EventService struct{}
+
+func (s *EventService) Update(req *common.Message, res *common.UpdateResponse) error {
+	res.Ok = true
+	return nil
+}
+
+func newOrderMessage(i int) *common.Message {
+	event := OrderEvent{
+		OrderId:   fmt.Sprintf("order-%08d", i),
+		Customer:  fmt.Sprintf("customer-%04d@example.com", i%1000),
+		Country:   "FR",
+		Status:    "created",
+		CreatedAt: time.Unix(1700000000, 0).UTC(),
+	}
+	for j := range 10 {
+		event.Items = append(event.Items, OrderItem{
+			Sku:      fmt.Sprintf("SKU-%05d", j),
+			Label:    "Standard shipping box, recycled cardboard",
+			Quantity: j + 1,
+			Price:    9.99,
+		})
+	}
+
+	return &common.Message{Topic: "orders.eu.created", Payload: event, Seq: uint64(i)}
+}
+
+func BenchmarkPublishPayload(b *testing.B) {
+	gob.Register(OrderEvent{})
+
+	for _, compression := range []string{"", Gzip, Flate} {
+		for _, name := range []string{Gob, JSONRPC, MessagePack} {
+			label := compression
+			if label == "" {
+				label = "none"
+			}
+
+			b.Run(label+"/"+name, func(b *testing.B) {
+				codec, _ := Lookup(name)
+				var wire atomic.Int64
+
+				var cli *rpc.Client
+				if compression == "" {
+					serverConn, clientConn := net.Pipe()
+					srv := rpc.NewServer()
+					srv.Register(new(EventService))
+					go srv.ServeCodec(codec.NewServerCodec(serverConn))
+					cli = rpc.NewClientWithCodec(codec.NewClientCodec(&countingConn{Conn: clientConn, written: &wire}))
+					b.Cleanup(func() { cli.Close() })
+				} else {
+					compressor, _ := LookupCompressor(compression)
+					cli = connectCompressed(b, codec, compressor, &wire)
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; b.Loop(); i++ {
+					var res common.UpdateResponse
+					if err := cli.Call("EventService.Update", newOrderMessage(i), &res); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(wire.Load())/float64(b.N), "wire-B/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+	"log"
+	"net/rpc"
+)
+
+/**
+ * The gobCodec is the default codec, compatible with the standard net/rpc clients and servers
+ */
+type gobCodec struct{}
+
+func (gobCodec) NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return newGobServerCodec(conn)
+}
+
+func (gobCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return newGobClientCodec(conn)
+}
+
+/**
+ * The gobServerCodec is the default net/rpc gob server codec, which the standard library does not export
+ */
+type gobServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+}
+
+func newGobServerCodec(conn io.ReadWriteCloser) *gobServerCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (c *gobServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobServerCodec) ReadRequestBody(body any) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobServerCodec) WriteResponse(r *rpc.Response, body any) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding response:", err)
+			c.Close()
+		}
+		return err
+	}
+
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("rpc: gob error encoding body:", err)
+			c.Close()
+		}
+		return err
+	}
+
+	return c.encBuf.Flush()
+}
+
+func (c *gobServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	return c.rwc.Close()
+}
+
+/**
+ * The gobClientCodec is the default net/rpc gob client codec, which the standard library does not export
+ */
+type gobClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+}
+
+func newGobClientCodec(conn io.ReadWriteCloser) *gobClientCodec {
+	buf := bufio.NewWriter(conn)
+	return &gobClientCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+func (c *gobClientCodec) WriteRequest(r *rpc.Request, body any) error {
+	if err := c.enc.Encode(r); err != nil {
+		return err
+	}
+
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+
+	return c.encBuf.Flush()
+}
+
+func (c *gobClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *gobClientCodec) ReadResponseBody(body any) error {
+	return c.dec.Decode(body)
+}
+
+func (c *gobClientCodec) Close() error {
+	return c.rwc.Close()
+}
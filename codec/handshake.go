@@ -0,0 +1,204 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// The first bytes of a connexion opened with a handshake
+var Magic = [4]byte{'M', 'N', 'E', 'T'}
+
+// The wire protocol versions this build can speak
+const (
+	MinVersion uint8 = 1
+	MaxVersion uint8 = 1
+)
+
+// The status of the server's answer
+const (
+	accepted uint8 = iota
+	refused
+)
+
+/**
+ * The Preamble is sent by a client before its first request to negotiate the connexion
+ * An empty Codec lets the server choose, an empty Compression disables it
+ */
+type Preamble struct {
+	Version     uint8
+	Codec       string
+	Compression string
+}
+
+/**
+ * The Answer is the server's reply to a Preamble, with the negotiated codec or the reason of the refusal
+ */
+type Answer struct {
+	Version     uint8
+	Accepted    bool
+	Codec       string
+	Compression string
+	Reason      string
+}
+
+/**
+ * WritePreamble sends the magic bytes and the preamble
+ */
+func WritePreamble(w io.Writer, preamble Preamble) error {
+	var buf bytes.Buffer
+	buf.Write(Magic[:])
+	buf.WriteByte(preamble.Version)
+	writeString8(&buf, preamble.Codec)
+	writeString8(&buf, preamble.Compression)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+/**
+ * ReadPreamble reads a preamble whose magic bytes were already consumed
+ */
+func ReadPreamble(r *bufio.Reader) (Preamble, error) {
+	var preamble Preamble
+	var err error
+
+	if preamble.Version, err = r.ReadByte(); err != nil {
+		return preamble, err
+	}
+	if preamble.Codec, err = readString8(r); err != nil {
+		return preamble, err
+	}
+	if preamble.Compression, err = readString8(r); err != nil {
+		return preamble, err
+	}
+
+	return preamble, nil
+}
+
+/**
+ * WriteAnswer sends the server's answer to a preamble
+ */
+func WriteAnswer(w io.Writer, answer Answer) error {
+	var buf bytes.Buffer
+	buf.Write(Magic[:])
+	buf.WriteByte(answer.Version)
+	if answer.Accepted {
+		buf.WriteByte(accepted)
+	} else {
+		buf.WriteByte(refused)
+	}
+	writeString8(&buf, answer.Codec)
+	writeString8(&buf, answer.Compression)
+	binary.Write(&buf, binary.BigEndian, uint16(len(answer.Reason)))
+	buf.WriteString(answer.Reason)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+/**
+ * ReadAnswer reads the server's answer, magic bytes included
+ */
+func ReadAnswer(r io.Reader) (Answer, error) {
+	var answer Answer
+	br := bufio.NewReaderSize(r, 16)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return answer, err
+	}
+	if magic != Magic {
+		return answer, fmt.Errorf("not a micronet server")
+	}
+
+	version, err := br.ReadByte()
+	if err != nil {
+		return answer, err
+	}
+	status, err := br.ReadByte()
+	if err != nil {
+		return answer, err
+	}
+	answer.Version, answer.Accepted = version, status == accepted
+
+	if answer.Codec, err = readString8(br); err != nil {
+		return answer, err
+	}
+	if answer.Compression, err = readString8(br); err != nil {
+		return answer, err
+	}
+
+	var length uint16
+	if err = binary.Read(br, binary.BigEndian, &length); err != nil {
+		return answer, err
+	}
+	reason := make([]byte, length)
+	if _, err = io.ReadFull(br, reason); err != nil {
+		return answer, err
+	}
+	answer.Reason = string(reason)
+
+	if br.Buffered() > 0 {
+		return answer, fmt.Errorf("unexpected data after handshake answer")
+	}
+
+	return answer, nil
+}
+
+/**
+ * SupportsVersion tells if this build can speak a wire protocol version
+ */
+func SupportsVersion(version uint8) bool {
+	return version >= MinVersion && version <= MaxVersion
+}
+
+/**
+ * IsPreamble tells if a connexion starts with the magic bytes, without consuming them otherwise
+ * @param r buffers the connexion, it must be read instead of the connexion afterwards
+ * @return true once the magic bytes are consumed
+ */
+func IsPreamble(r *bufio.Reader) bool {
+	peeked, err := r.Peek(len(Magic))
+	if err != nil || !bytes.Equal(peeked, Magic[:]) {
+		return false
+	}
+
+	r.Discard(len(Magic))
+	return true
+}
+
+/**
+ * The BufferedConn is a connexion whose first bytes were read into a bufio.Reader
+ */
+type BufferedConn struct {
+	net.Conn
+	Reader *bufio.Reader
+}
+
+func (c *BufferedConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
+
+func writeString8(buf *bytes.Buffer, s string) {
+	s = s[:min(len(s), 255)]
+	buf.WriteByte(uint8(len(s)))
+	buf.WriteString(s)
+}
+
+func readString8(r *bufio.Reader) (string, error) {
+	length, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	s := make([]byte, length)
+	if _, err = io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+
+	return string(s), nil
+}
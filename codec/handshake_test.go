@@ -0,0 +1,71 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshake_Preamble(t *testing.T) {
+	t.Run("Preamble round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		sent := Preamble{Version: MaxVersion, Codec: MessagePack, Compression: "gzip"}
+		assert.NoError(t, WritePreamble(&buf, sent))
+
+		reader := bufio.NewReader(&buf)
+		assert.True(t, IsPreamble(reader))
+		received, err := ReadPreamble(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, sent, received)
+	})
+
+	t.Run("Connexion without preamble is left untouched", func(t *testing.T) {
+		reader := bufio.NewReader(bytes.NewBufferString(`{"method":"PingHandler.Ping"}`))
+		assert.False(t, IsPreamble(reader))
+
+		data, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"method":"PingHandler.Ping"}`, string(data))
+	})
+
+	t.Run("Short connexion is not a preamble", func(t *testing.T) {
+		assert.False(t, IsPreamble(bufio.NewReader(bytes.NewBufferString("MN"))))
+	})
+}
+
+func TestHandshake_Answer(t *testing.T) {
+	t.Run("Accepted answer round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		sent := Answer{Version: MaxVersion, Accepted: true, Codec: JSONRPC}
+		assert.NoError(t, WriteAnswer(&buf, sent))
+
+		received, err := ReadAnswer(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, sent, received)
+	})
+
+	t.Run("Refused answer carries the reason", func(t *testing.T) {
+		var buf bytes.Buffer
+		sent := Answer{Version: MaxVersion, Reason: "unsupported protocol version 9"}
+		assert.NoError(t, WriteAnswer(&buf, sent))
+
+		received, err := ReadAnswer(&buf)
+		assert.NoError(t, err)
+		assert.False(t, received.Accepted)
+		assert.Equal(t, "unsupported protocol version 9", received.Reason)
+	})
+
+	t.Run("Answer of a foreign server", func(t *testing.T) {
+		_, err := ReadAnswer(bytes.NewBufferString(`{"id":0,"result":null,"error":"bad request"}`))
+		assert.EqualError(t, err, "not a micronet server")
+	})
+
+	t.Run("Versions", func(t *testing.T) {
+		assert.True(t, SupportsVersion(MaxVersion))
+		assert.False(t, SupportsVersion(MinVersion-1))
+		assert.False(t, SupportsVersion(MaxVersion+1))
+	})
+}
@@ -0,0 +1,20 @@
+package codec
+
+import (
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+)
+
+/**
+ * The jsonCodec is the standard library's JSON-RPC 1.0, to talk with services written in other languages
+ */
+type jsonCodec struct{}
+
+func (jsonCodec) NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return jsonrpc.NewServerCodec(conn)
+}
+
+func (jsonCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return jsonrpc.NewClientCodec(conn)
+}
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/rpc"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/**
+ * The msgpackCodec writes the request or response header followed by its body as two MessagePack values
+ * Structures are encoded as maps keyed by field name, to be read by MessagePack libraries of other languages
+ */
+type msgpackCodec struct{}
+
+func (msgpackCodec) NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return newMsgpackServerCodec(conn)
+}
+
+func (msgpackCodec) NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return newMsgpackClientCodec(conn)
+}
+
+/**
+ * The msgpackStream is the encoder and decoder of a connexion
+ */
+type msgpackStream struct {
+	rwc    io.ReadWriteCloser
+	dec    *msgpack.Decoder
+	enc    *msgpack.Encoder
+	encBuf *bufio.Writer
+}
+
+func newMsgpackStream(conn io.ReadWriteCloser) msgpackStream {
+	buf := bufio.NewWriter(conn)
+	return msgpackStream{
+		rwc:    conn,
+		dec:    msgpack.NewDecoder(bufio.NewReader(conn)),
+		enc:    msgpack.NewEncoder(buf),
+		encBuf: buf,
+	}
+}
+
+/**
+ * write encodes a header and its body in a single flush
+ */
+func (s *msgpackStream) write(header any, body any) error {
+	if err := s.enc.Encode(header); err != nil {
+		return err
+	}
+
+	if err := s.enc.Encode(body); err != nil {
+		return err
+	}
+
+	return s.encBuf.Flush()
+}
+
+/**
+ * readBody decodes a body, or skips it when body is nil
+ */
+func (s *msgpackStream) readBody(body any) error {
+	if body == nil {
+		return s.dec.Skip()
+	}
+
+	return s.dec.Decode(body)
+}
+
+type msgpackServerCodec struct {
+	msgpackStream
+	closed bool
+}
+
+func newMsgpackServerCodec(conn io.ReadWriteCloser) *msgpackServerCodec {
+	return &msgpackServerCodec{msgpackStream: newMsgpackStream(conn)}
+}
+
+func (c *msgpackServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	return c.dec.Decode(r)
+}
+
+func (c *msgpackServerCodec) ReadRequestBody(body any) error {
+	return c.readBody(body)
+}
+
+func (c *msgpackServerCodec) WriteResponse(r *rpc.Response, body any) error {
+	err := c.write(r, body)
+	if err != nil && c.encBuf.Flush() == nil {
+		log.Println("rpc: msgpack error encoding response:", err)
+		c.Close()
+	}
+
+	return err
+}
+
+func (c *msgpackServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	return c.rwc.Close()
+}
+
+type msgpackClientCodec struct {
+	msgpackStream
+}
+
+func newMsgpackClientCodec(conn io.ReadWriteCloser) *msgpackClientCodec {
+	return &msgpackClientCodec{msgpackStream: newMsgpackStream(conn)}
+}
+
+func (c *msgpackClientCodec) WriteRequest(r *rpc.Request, body any) error {
+	return c.write(r, body)
+}
+
+func (c *msgpackClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *msgpackClientCodec) ReadResponseBody(body any) error {
+	return c.readBody(body)
+}
+
+func (c *msgpackClientCodec) Close() error {
+	return c.rwc.Close()
+}
@@ -1,6 +1,29 @@
 package common
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/**
+ * The Micronet errors rebuilt by DecodeError, MicronetTimeoutError is left out as its Err cannot be encoded
+ */
+func init() {
+	registerError[MicronetReconnectTimeoutError]()
+	registerError[MicronetShutdownError]()
+	registerError[MicronetInvalidTopicError]()
+	registerError[MicronetQueueFullError]()
+	registerError[MicronetDecodeError]()
+	registerError[MicronetInternalError]()
+	registerError[MicronetUnknownCodecError]()
+	registerError[MicronetHandshakeError]()
+	registerError[MicronetUnknownCompressionError]()
+	registerError[MicronetNoEndpointError]()
+	registerError[MicronetCircuitOpenError]()
+	registerError[MicronetUnknownInstanceError]()
+	registerError[MicronetUnknownServiceError]()
+}
 
 type MicronetReconnectTimeoutError struct {
 	NetConf
@@ -9,3 +32,175 @@ type MicronetReconnectTimeoutError struct {
 func (e MicronetReconnectTimeoutError) Error() string {
 	return fmt.Sprintf("connexion timeout to %s:%s", e.Ip, e.Port)
 }
+
+func (e MicronetReconnectTimeoutError) Status() *Status {
+	return newErrorStatus(Unavailable, e)
+}
+
+type MicronetShutdownError struct {
+	NetConf
+}
+
+func (e MicronetShutdownError) Error() string {
+	return fmt.Sprintf("server %s:%s is shutting down", e.Ip, e.Port)
+}
+
+func (e MicronetShutdownError) Status() *Status {
+	return newErrorStatus(Unavailable, e)
+}
+
+type MicronetTimeoutError struct {
+	NetConf
+	ServiceMethod string
+	Err           error
+}
+
+func (e MicronetTimeoutError) Error() string {
+	return fmt.Sprintf("call %s to %s:%s aborted: %s", e.ServiceMethod, e.Ip, e.Port, e.Err)
+}
+
+func (e MicronetTimeoutError) Status() *Status {
+	if errors.Is(e.Err, context.Canceled) {
+		return NewStatus(Canceled, e.Error())
+	}
+	return NewStatus(DeadlineExceeded, e.Error())
+}
+
+func (e MicronetTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+type MicronetInvalidTopicError struct {
+	Topic string
+}
+
+func (e MicronetInvalidTopicError) Error() string {
+	return fmt.Sprintf("invalid topic %q", e.Topic)
+}
+
+func (e MicronetInvalidTopicError) Status() *Status {
+	return newErrorStatus(InvalidArgument, e)
+}
+
+type MicronetQueueFullError struct {
+	NetConf
+}
+
+func (e MicronetQueueFullError) Error() string {
+	return fmt.Sprintf("queue of subscriber %s:%s is full", e.Ip, e.Port)
+}
+
+func (e MicronetQueueFullError) Status() *Status {
+	return newErrorStatus(ResourceExhausted, e)
+}
+
+type MicronetDecodeError struct {
+	Topic    string
+	Expected string
+	Actual   string
+}
+
+func (e MicronetDecodeError) Error() string {
+	return fmt.Sprintf("message of topic %q is a %s, expected a %s", e.Topic, e.Actual, e.Expected)
+}
+
+func (e MicronetDecodeError) Status() *Status {
+	return newErrorStatus(InvalidArgument, e)
+}
+
+type MicronetInternalError struct {
+	ServiceMethod string
+}
+
+func (e MicronetInternalError) Error() string {
+	return fmt.Sprintf("internal error in %s", e.ServiceMethod)
+}
+
+func (e MicronetInternalError) Status() *Status {
+	return newErrorStatus(Internal, e)
+}
+
+type MicronetUnknownCodecError struct {
+	Codec string
+}
+
+func (e MicronetUnknownCodecError) Error() string {
+	return fmt.Sprintf("unknown codec %q", e.Codec)
+}
+
+func (e MicronetUnknownCodecError) Status() *Status {
+	return newErrorStatus(InvalidArgument, e)
+}
+
+type MicronetHandshakeError struct {
+	NetConf
+	Reason string
+}
+
+func (e MicronetHandshakeError) Error() string {
+	return fmt.Sprintf("handshake with %s:%s failed: %s", e.Ip, e.Port, e.Reason)
+}
+
+func (e MicronetHandshakeError) Status() *Status {
+	return newErrorStatus(FailedPrecondition, e)
+}
+
+type MicronetUnknownCompressionError struct {
+	Compression string
+}
+
+func (e MicronetUnknownCompressionError) Error() string {
+	return fmt.Sprintf("unknown compression %q", e.Compression)
+}
+
+func (e MicronetUnknownCompressionError) Status() *Status {
+	return newErrorStatus(InvalidArgument, e)
+}
+
+type MicronetNoEndpointError struct {
+	ServiceMethod string
+}
+
+func (e MicronetNoEndpointError) Error() string {
+	return fmt.Sprintf("no healthy endpoint to call %s", e.ServiceMethod)
+}
+
+func (e MicronetNoEndpointError) Status() *Status {
+	return newErrorStatus(Unavailable, e)
+}
+
+type MicronetCircuitOpenError struct {
+	NetConf
+}
+
+func (e MicronetCircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit to %s:%s is open", e.Ip, e.Port)
+}
+
+func (e MicronetCircuitOpenError) Status() *Status {
+	return newErrorStatus(Unavailable, e)
+}
+
+type MicronetUnknownInstanceError struct {
+	NetConf
+}
+
+func (e MicronetUnknownInstanceError) Error() string {
+	return fmt.Sprintf("no lease for instance %s at %s:%s", e.Name, e.Ip, e.Port)
+}
+
+func (e MicronetUnknownInstanceError) Status() *Status {
+	return newErrorStatus(NotFound, e)
+}
+
+type MicronetUnknownServiceError struct {
+	Name string
+}
+
+func (e MicronetUnknownServiceError) Error() string {
+	return fmt.Sprintf("no instance of service %s", e.Name)
+}
+
+func (e MicronetUnknownServiceError) Status() *Status {
+	return newErrorStatus(NotFound, e)
+}
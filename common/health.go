@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+/**
+ * The HealthStatus tells whether a Server, or one of its services, can serve calls
+ */
+type HealthStatus int
+
+const (
+	HealthUnknown HealthStatus = iota
+	HealthServing
+	HealthNotServing
+)
+
+var healthStatusNames = [...]string{
+	HealthUnknown:    "UNKNOWN",
+	HealthServing:    "SERVING",
+	HealthNotServing: "NOT_SERVING",
+}
+
+func (s HealthStatus) String() string {
+	if s >= 0 && int(s) < len(healthStatusNames) {
+		return healthStatusNames[s]
+	}
+
+	return fmt.Sprintf("HealthStatus(%d)", int(s))
+}
+
+/**
+ * The HealthCheckRequest asks for the status of a registered service, or of the whole Server when Service is empty
+ */
+type HealthCheckRequest struct {
+	Service string
+}
+
+/**
+ * The HealthCheckResponse is a status and its version, incremented on every change
+ */
+type HealthCheckResponse struct {
+	Status  HealthStatus
+	Version uint64
+}
+
+/**
+ * The HealthWatchRequest waits until the status of a service is no longer at Version, for at most Timeout
+ */
+type HealthWatchRequest struct {
+	Service string
+	Version uint64
+	Timeout time.Duration
+}
+
+type HealthWatchResponse struct {
+	Status  HealthStatus
+	Version uint64
+}
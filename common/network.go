@@ -1,15 +1,20 @@
 package common
 
+import "time"
+
 const (
 	PING string = "PING"
 	PONG string = "PONG"
 )
 
 type NetConf struct {
-	Name     string
-	Ip       string
-	Port     string
-	Protocol string
+	Name        string
+	Ip          string
+	Port        string
+	Protocol    string
+	Codec       string // "gob" when empty, "jsonrpc", "msgpack" or any codec.Register name
+	Handshake   bool   // negotiate the codec and protocol version when connecting to this Micronet server
+	Compression string // "gzip", "flate" or any codec.RegisterCompressor name, negotiated by the handshake
 }
 
 type Ping struct {
@@ -33,8 +38,97 @@ func (p *PingHandler) Ping(req *Ping, res *Pong) error {
 type SubscribeRequest struct {
 	Subscriber NetConf
 	Publisher  NetConf
+	Topics     []string
+	Replay     bool
+	FromOffset uint64
+	FromTime   time.Time
 }
 
 type SubscribeResponse struct {
 	Ok bool
 }
+
+type Message struct {
+	Topic   string
+	Payload any
+	Stream  int64
+	Seq     uint64
+	Offset  uint64
+}
+
+type AckRequest struct {
+	Subscriber NetConf
+	Stream     int64
+	Seq        uint64
+}
+
+type AckResponse struct {
+	Ok bool
+}
+
+type UpdateResponse struct {
+	Ok bool
+}
+
+/**
+ * The PeerInfo describes the caller of a handler
+ * Embed it in a request structure to have the Server fill it, any value sent by the caller is overwritten
+ * PeerIdentity is the common name of the client certificate verified by mutual TLS
+ */
+type PeerInfo struct {
+	PeerAddr     string
+	PeerIdentity string
+	PeerVerified bool
+}
+
+func (p *PeerInfo) SetPeer(info PeerInfo) {
+	*p = info
+}
+
+/**
+ * The PeerSetter is implemented by the requests embedding PeerInfo
+ */
+type PeerSetter interface {
+	SetPeer(PeerInfo)
+}
+
+/**
+ * The ServiceInstance is a Server registered under its NetConf.Name, with its user metadata
+ */
+type ServiceInstance struct {
+	NetConf  NetConf
+	Metadata map[string]string
+}
+
+type RegisterRequest struct {
+	Instance ServiceInstance
+	TTL      time.Duration
+}
+
+type RegisterResponse struct {
+	TTL time.Duration
+}
+
+type HeartbeatRequest struct {
+	NetConf NetConf
+}
+
+type HeartbeatResponse struct {
+	Ok bool
+}
+
+type DeregisterRequest struct {
+	NetConf NetConf
+}
+
+type DeregisterResponse struct {
+	Ok bool
+}
+
+type ResolveRequest struct {
+	Name string
+}
+
+type ResolveResponse struct {
+	Instances []ServiceInstance
+}
@@ -0,0 +1,238 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"strings"
+)
+
+/**
+ * The Code classifies an error, its values match the gRPC status codes
+ */
+type Code int
+
+const (
+	OK Code = iota
+	Canceled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+var codeNames = [...]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	OutOfRange:         "OutOfRange",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	DataLoss:           "DataLoss",
+	Unauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// The prefix of a Status encoded in the error string of an rpc response
+const statusPrefix = "micronet-status:"
+
+// The details of a Status made of a Micronet error: the error's type and its JSON encoded fields
+const (
+	errorTypeDetail   = "micronet-error"
+	errorFieldsDetail = "micronet-fields"
+)
+
+// The decoders of the Micronet errors, by type name
+var errorDecoders = map[string]func(fields []byte) (error, bool){}
+
+/**
+ * The Status is an error with a Code that survives the rpc boundary
+ * A handler returning a Status, or an error wrapping one, has it reconstructed by the Client so that errors.As works
+ */
+type Status struct {
+	Code    Code              `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+/**
+ * NewStatus creates a Status error
+ * @param code classifies the error
+ * @param message describes the error
+ */
+func NewStatus(code Code, message string) *Status {
+	return &Status{Code: code, Message: message}
+}
+
+/**
+ * Errorf creates a Status error with a formatted message
+ */
+func Errorf(code Code, format string, args ...any) *Status {
+	return NewStatus(code, fmt.Sprintf(format, args...))
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("%s: %s", s.Code, s.Message)
+}
+
+/**
+ * WithDetail returns a copy of the Status with an additional detail
+ */
+func (s *Status) WithDetail(key string, value string) *Status {
+	details := make(map[string]string, len(s.Details)+1)
+	for k, v := range s.Details {
+		details[k] = v
+	}
+	details[key] = value
+
+	return &Status{Code: s.Code, Message: s.Message, Details: details}
+}
+
+/**
+ * Origin is the type name of the Micronet error the Status was made of, like "common.MicronetShutdownError"
+ * @return the type name, empty for a Status created by a handler
+ */
+func (s *Status) Origin() string {
+	return s.Details[errorTypeDetail]
+}
+
+/**
+ * registerError lets DecodeError rebuild the Micronet error E from its Status
+ */
+func registerError[E error]() {
+	var zero E
+	errorDecoders[fmt.Sprintf("%T", zero)] = func(fields []byte) (error, bool) {
+		var e E
+		if err := json.Unmarshal(fields, &e); err != nil {
+			return nil, false
+		}
+		return e, true
+	}
+}
+
+/**
+ * newErrorStatus is the Status of a Micronet error, with the details DecodeError needs to rebuild it
+ */
+func newErrorStatus(code Code, err error) *Status {
+	status := NewStatus(code, err.Error())
+	fields, errMarshal := json.Marshal(err)
+	if errMarshal != nil {
+		return status
+	}
+
+	return status.WithDetail(errorTypeDetail, fmt.Sprintf("%T", err)).WithDetail(errorFieldsDetail, string(fields))
+}
+
+/**
+ * The statusError is implemented by the Micronet errors that have a Code
+ */
+type statusError interface {
+	Status() *Status
+}
+
+/**
+ * StatusOf finds the Status of an error
+ * @param err is any error, possibly wrapping a Status or a Micronet error
+ * @return the error's Status and true, or an Unknown Status and false
+ */
+func StatusOf(err error) (*Status, bool) {
+	var status *Status
+	if errors.As(err, &status) {
+		return status, true
+	}
+
+	var withStatus statusError
+	if errors.As(err, &withStatus) {
+		return withStatus.Status(), true
+	}
+
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+
+	return NewStatus(Unknown, message), false
+}
+
+/**
+ * CodeOf is the Code of an error, OK for nil and Unknown for an error without Status
+ */
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+
+	status, _ := StatusOf(err)
+	return status.Code
+}
+
+/**
+ * EncodeError is the error string of an rpc response, a Status is encoded to be decoded by DecodeError
+ */
+func EncodeError(err error) string {
+	status, ok := StatusOf(err)
+	if !ok {
+		return err.Error()
+	}
+
+	encoded, errMarshal := json.Marshal(status)
+	if errMarshal != nil {
+		return err.Error()
+	}
+
+	return statusPrefix + string(encoded)
+}
+
+/**
+ * DecodeError reconstructs the Status sent by the remote handler
+ * A Micronet error is rebuilt as itself, so that errors.As(err, &common.MicronetInternalError{}) works on the Client
+ * @param err is the error of an rpc call
+ * @return the Micronet error or the *Status if err is an encoded rpc.ServerError, err otherwise
+ */
+func DecodeError(err error) error {
+	var errServer rpc.ServerError
+	if !errors.As(err, &errServer) || !strings.HasPrefix(string(errServer), statusPrefix) {
+		return err
+	}
+
+	status := &Status{}
+	if errUnmarshal := json.Unmarshal([]byte(strings.TrimPrefix(string(errServer), statusPrefix)), status); errUnmarshal != nil {
+		return err
+	}
+
+	if decode, ok := errorDecoders[status.Details[errorTypeDetail]]; ok {
+		if typed, ok := decode([]byte(status.Details[errorFieldsDetail])); ok {
+			return typed
+		}
+	}
+
+	return status
+}
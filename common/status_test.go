@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_EncodeDecode(t *testing.T) {
+	t.Run("Status survives the wire", func(t *testing.T) {
+		status := Errorf(PermissionDenied, "user %d cannot read", 42).WithDetail("resource", "orders")
+
+		decoded := DecodeError(rpc.ServerError(EncodeError(fmt.Errorf("wrapped: %w", status))))
+
+		assert.Equal(t, status, decoded)
+		assert.EqualError(t, decoded, "PermissionDenied: user 42 cannot read")
+	})
+
+	t.Run("Micronet errors are encoded with their code", func(t *testing.T) {
+		decoded := DecodeError(rpc.ServerError(EncodeError(MicronetInternalError{ServiceMethod: "Service.Method"})))
+
+		assert.Equal(t, Internal, CodeOf(decoded))
+		assert.Equal(t, MicronetInternalError{ServiceMethod: "Service.Method"}, decoded)
+		assert.ErrorAs(t, decoded, &MicronetInternalError{})
+	})
+
+	t.Run("Micronet errors with a NetConf are rebuilt", func(t *testing.T) {
+		err := MicronetShutdownError{NetConf: NetConf{Ip: "127.0.0.1", Port: "4321"}}
+
+		decoded := DecodeError(rpc.ServerError(EncodeError(fmt.Errorf("wrapped: %w", err))))
+
+		assert.Equal(t, err, decoded)
+		assert.Equal(t, Unavailable, CodeOf(decoded))
+	})
+
+	t.Run("Plain errors are left untouched", func(t *testing.T) {
+		assert.Equal(t, "not found", EncodeError(errors.New("not found")))
+		assert.Equal(t, rpc.ServerError("not found"), DecodeError(rpc.ServerError("not found")))
+		assert.Equal(t, rpc.ServerError(statusPrefix+"{"), DecodeError(rpc.ServerError(statusPrefix+"{")))
+	})
+}
+
+func TestStatus_CodeOf(t *testing.T) {
+	assert.Equal(t, OK, CodeOf(nil))
+	assert.Equal(t, Unknown, CodeOf(errors.New("plain")))
+	assert.Equal(t, NotFound, CodeOf(fmt.Errorf("wrapped: %w", NewStatus(NotFound, "missing"))))
+	assert.Equal(t, Unavailable, CodeOf(MicronetShutdownError{}))
+	assert.Equal(t, DeadlineExceeded, CodeOf(MicronetTimeoutError{Err: context.DeadlineExceeded}))
+	assert.Equal(t, Canceled, CodeOf(MicronetTimeoutError{Err: context.Canceled}))
+	assert.Equal(t, "Code(99)", Code(99).String())
+}
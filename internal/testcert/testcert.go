@@ -0,0 +1,109 @@
+package testcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+/**
+ * The Authority is a self-signed certificate authority issuing test certificates
+ */
+type Authority struct {
+	Pool *x509.CertPool
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+/**
+ * NewAuthority creates a self-signed certificate authority
+ */
+func NewAuthority(t testing.TB) *Authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "micronet test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &Authority{Pool: pool, cert: cert, key: key}
+}
+
+/**
+ * Issue creates a certificate for 127.0.0.1 and localhost, usable by a server and a client
+ * @param commonName is the certificate's subject common name
+ */
+func (a *Authority) Issue(t testing.TB, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+/**
+ * ServerConfig is a server configuration requiring and verifying client certificates
+ */
+func (a *Authority) ServerConfig(t testing.TB) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{a.Issue(t, "server")},
+		ClientCAs:    a.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+/**
+ * ClientConfig is a client configuration trusting the authority and presenting a certificate
+ * @param commonName is the client certificate's subject common name
+ */
+func (a *Authority) ClientConfig(t testing.TB, commonName string) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{a.Issue(t, commonName)},
+		RootCAs:      a.Pool,
+	}
+}
@@ -0,0 +1,558 @@
+package common
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"micronet/common"
+)
+
+const segmentExtension string = ".log"
+
+/**
+ * The LogConf configures a MessageLog
+ * Dir is the directory holding the segment files
+ * SegmentSize is the size in bytes after which a new segment is started
+ * MaxSize and MaxAge bound the retention of the closed segments, 0 means no limit
+ * MaxSize is applied when a segment is closed, MaxAge also every tenth of MaxAge
+ * Sync flushes every append to the disk
+ */
+type LogConf struct {
+	Dir         string
+	SegmentSize int64
+	MaxSize     int64
+	MaxAge      time.Duration
+	Sync        bool
+}
+
+/**
+ * DefaultSegmentSize is used when LogConf.SegmentSize is not set
+ */
+const DefaultSegmentSize int64 = 16 << 20
+
+/**
+ * The LogRecord is a published message as stored in the MessageLog
+ */
+type LogRecord struct {
+	Offset  uint64
+	Time    time.Time
+	Topic   string
+	Payload any
+}
+
+/**
+ * The MessageLog is a segmented append-only log of published messages
+ * Every record is a length prefixed, self-contained gob encoding, so the payload types must be registered to gob
+ * A segment removed by the retention while it is being read is deleted once the reading is done
+ */
+type MessageLog struct {
+	mutex      sync.Mutex
+	conf       LogConf
+	segments   []*segment
+	active     *os.File
+	nextOffset uint64
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+/**
+ * The segment is a file of records starting at the base offset, baseTime is the time of its first record
+ * readers counts the readings holding it, removed marks it as deleted by the retention
+ */
+type segment struct {
+	base     uint64
+	baseTime time.Time
+	path     string
+	size     int64
+	readers  int
+	removed  bool
+}
+
+/**
+ * OpenMessageLog opens or creates the log in conf.Dir, recovering its next offset
+ * A record partially written by a crash is truncated
+ * @param conf is the log's configuration
+ * @return the opened MessageLog or error
+ */
+func OpenMessageLog(conf LogConf) (*MessageLog, error) {
+	if conf.SegmentSize <= 0 {
+		conf.SegmentSize = DefaultSegmentSize
+	}
+
+	if err := os.MkdirAll(conf.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(conf.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &MessageLog{conf: conf}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+
+		base, errParse := strconv.ParseUint(strings.TrimSuffix(name, segmentExtension), 10, 64)
+		if errParse != nil {
+			continue
+		}
+
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			return nil, errInfo
+		}
+		path := filepath.Join(conf.Dir, name)
+		l.segments = append(l.segments, &segment{base: base, baseTime: readBaseTime(path), path: path, size: info.Size()})
+	}
+	slices.SortFunc(l.segments, func(a, b *segment) int {
+		return cmp.Compare(a.base, b.base)
+	})
+
+	if len(l.segments) == 0 {
+		if err := l.roll(); err != nil {
+			return nil, err
+		}
+	} else {
+		last := l.segments[len(l.segments)-1]
+		count, size, errRecover := recoverSegment(last.path)
+		if errRecover != nil {
+			return nil, errRecover
+		}
+		last.size = size
+		l.nextOffset = last.base + count
+
+		l.active, err = os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.MaxAge > 0 {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.expire(l.stop, max(conf.MaxAge/10, time.Millisecond))
+	}
+
+	return l, nil
+}
+
+/**
+ * expire applies the retention at every interval until the log is closed, so that old segments expire without appends
+ */
+func (l *MessageLog) expire(stop chan struct{}, interval time.Duration) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			l.mutex.Lock()
+			err := l.retain()
+			l.mutex.Unlock()
+			if err != nil {
+				log.Printf("Error applying the log retention: %s", err)
+			}
+		}
+	}
+}
+
+/**
+ * recoverSegment counts the complete records of a segment and truncates a partial trailing record
+ */
+func recoverSegment(path string) (uint64, int64, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	var count uint64
+	var size int64
+	for {
+		n, errRead := skipRecord(file)
+		if errRead != nil {
+			break
+		}
+		count++
+		size += n
+	}
+
+	return count, size, file.Truncate(size)
+}
+
+/**
+ * Append writes a message at the end of the log
+ * @param topic is the message's topic
+ * @param payload is the message of any type registered to gob
+ * @return the message's offset or error
+ */
+func (l *MessageLog) Append(topic string, payload any) (uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.active == nil {
+		return 0, os.ErrClosed
+	}
+
+	record := LogRecord{Offset: l.nextOffset, Time: time.Now(), Topic: topic, Payload: payload}
+
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(&record); err != nil {
+		return 0, err
+	}
+
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+body.Len()), uint32(body.Len()))
+	frame = append(frame, body.Bytes()...)
+	if _, err := l.active.Write(frame); err != nil {
+		return 0, err
+	}
+
+	if l.conf.Sync {
+		if err := l.active.Sync(); err != nil {
+			return 0, err
+		}
+	}
+
+	current := l.segments[len(l.segments)-1]
+	if current.size == 0 {
+		current.baseTime = record.Time
+	}
+	current.size += int64(len(frame))
+	l.nextOffset++
+
+	if current.size >= l.conf.SegmentSize {
+		if err := l.roll(); err != nil {
+			return record.Offset, err
+		}
+	}
+
+	return record.Offset, nil
+}
+
+/**
+ * NextOffset is the offset the next appended message will get
+ */
+func (l *MessageLog) NextOffset() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.nextOffset
+}
+
+/**
+ * FirstOffset is the offset of the oldest retained message
+ */
+func (l *MessageLog) FirstOffset() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.segments[0].base
+}
+
+/**
+ * ReadRange calls fn for every retained record with an offset between from, included, and until, excluded
+ * Offsets older than the retention are skipped
+ * @param from is the first offset to read
+ * @param until is the offset to stop at
+ * @param fn is called in offset order, its error stops the reading
+ * @return a reading or fn's error
+ */
+func (l *MessageLog) ReadRange(from uint64, until uint64, fn func(*LogRecord) error) error {
+	segments := l.acquire(from, until)
+	defer l.release(segments)
+
+	for _, seg := range segments {
+		done, err := readSegment(seg.path, from, until, fn)
+		if err != nil || done {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**
+ * acquire selects the segments holding the records between from and until, the retention keeps them until released
+ */
+func (l *MessageLog) acquire(from uint64, until uint64) []*segment {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	segments := []*segment{}
+	for i, seg := range l.segments {
+		if i+1 < len(l.segments) && l.segments[i+1].base <= from {
+			continue
+		}
+		if seg.base >= until {
+			break
+		}
+
+		seg.readers++
+		segments = append(segments, seg)
+	}
+
+	return segments
+}
+
+/**
+ * release ends the reading of acquired segments, deleting those the retention removed meanwhile
+ */
+func (l *MessageLog) release(segments []*segment) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, seg := range segments {
+		seg.readers--
+		if seg.removed && seg.readers == 0 {
+			if err := os.Remove(seg.path); err != nil {
+				log.Printf("Error removing segment %s: %s", seg.path, err)
+			}
+		}
+	}
+}
+
+/**
+ * OffsetAt finds the offset of the first retained message published at or after t
+ * The segments whose successor starts before t are skipped without being read
+ * @param t is the time to look for
+ * @return the offset, NextOffset if every message is older
+ */
+func (l *MessageLog) OffsetAt(t time.Time) (uint64, error) {
+	l.mutex.Lock()
+	until := l.nextOffset
+	from := l.segments[0].base
+	for _, seg := range l.segments {
+		if !seg.baseTime.IsZero() && seg.baseTime.Before(t) {
+			from = seg.base
+		}
+	}
+	l.mutex.Unlock()
+
+	offset := until
+
+	errFound := errors.New("found")
+	err := l.ReadRange(from, until, func(record *LogRecord) error {
+		if record.Time.Before(t) {
+			return nil
+		}
+		offset = record.Offset
+		return errFound
+	})
+	if err != nil && err != errFound {
+		return 0, err
+	}
+
+	return offset, nil
+}
+
+/**
+ * Close the active segment and stop the retention
+ */
+func (l *MessageLog) Close() error {
+	l.mutex.Lock()
+	stop := l.stop
+	l.stop = nil
+	l.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-l.done
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.active == nil {
+		return nil
+	}
+
+	err := l.active.Close()
+	l.active = nil
+
+	return err
+}
+
+/**
+ * roll starts a new segment at the next offset, then applies the retention to the closed segments
+ */
+func (l *MessageLog) roll() error {
+	path := filepath.Join(l.conf.Dir, fmt.Sprintf("%020d%s", l.nextOffset, segmentExtension))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if l.active != nil {
+		l.active.Close()
+	}
+	l.active = file
+	l.segments = append(l.segments, &segment{base: l.nextOffset, path: path})
+
+	return l.retain()
+}
+
+/**
+ * retain deletes the oldest closed segments exceeding MaxSize or older than MaxAge
+ */
+func (l *MessageLog) retain() error {
+	var total int64
+	for _, seg := range l.segments {
+		total += seg.size
+	}
+
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+
+		expired := false
+		if l.conf.MaxAge > 0 {
+			info, err := os.Stat(oldest.path)
+			if err != nil {
+				return err
+			}
+			expired = time.Since(info.ModTime()) > l.conf.MaxAge
+		}
+
+		if !expired && (l.conf.MaxSize <= 0 || total <= l.conf.MaxSize) {
+			return nil
+		}
+
+		if oldest.readers > 0 {
+			oldest.removed = true
+		} else if err := os.Remove(oldest.path); err != nil {
+			return err
+		}
+		total -= oldest.size
+		l.segments = l.segments[1:]
+	}
+
+	return nil
+}
+
+/**
+ * readSegment calls fn for the records of a segment between from and until
+ * @return true once until is reached
+ */
+func readSegment(path string, from uint64, until uint64, fn func(*LogRecord) error) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	for {
+		record, errRead := readRecord(file)
+		if errRead == io.EOF || errors.Is(errRead, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		if errRead != nil {
+			return false, errRead
+		}
+
+		if record.Offset >= until {
+			return true, nil
+		}
+		if record.Offset < from {
+			continue
+		}
+
+		if err := fn(record); err != nil {
+			return true, err
+		}
+	}
+}
+
+/**
+ * readBaseTime is the time of the first record of a segment, without decoding its payload
+ * @return the zero time for an empty or unreadable segment
+ */
+func readBaseTime(path string) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer file.Close()
+
+	var size uint32
+	if err := binary.Read(file, binary.BigEndian, &size); err != nil {
+		return time.Time{}
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(file, body); err != nil {
+		return time.Time{}
+	}
+
+	// the payload is skipped by gob as the header has no such field
+	var header struct {
+		Time time.Time
+	}
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&header); err != nil {
+		return time.Time{}
+	}
+
+	return header.Time
+}
+
+func readRecord(r io.Reader) (*LogRecord, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	record := &LogRecord{}
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+/**
+ * skipRecord reads a complete record frame without decoding it
+ * @return the frame's size
+ */
+func skipRecord(r io.Reader) (int64, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyN(io.Discard, r, int64(size))
+	if err != nil {
+		return 0, err
+	}
+
+	return 4 + n, nil
+}
+
+/**
+ * toMessage converts a record to the message delivered to the Subscribers
+ */
+func (r *LogRecord) toMessage() *common.Message {
+	return &common.Message{Topic: r.Topic, Payload: r.Payload, Offset: r.Offset}
+}
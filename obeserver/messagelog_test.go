@@ -0,0 +1,214 @@
+package common
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, l *MessageLog, from uint64) []*LogRecord {
+	records := []*LogRecord{}
+	err := l.ReadRange(from, l.NextOffset(), func(record *LogRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	return records
+}
+
+func TestMessageLog(t *testing.T) {
+	t.Run("Append and read", func(t *testing.T) {
+		l, err := OpenMessageLog(LogConf{Dir: t.TempDir(), SegmentSize: 256})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		for i := 0; i < 20; i++ {
+			offset, errAppend := l.Append("orders", i)
+			assert.NoError(t, errAppend)
+			assert.Equal(t, uint64(i), offset)
+		}
+
+		assert.Greater(t, len(l.segments), 1)
+
+		records := readAll(t, l, 15)
+		if assert.Len(t, records, 5) {
+			assert.Equal(t, uint64(15), records[0].Offset)
+			assert.Equal(t, "orders", records[0].Topic)
+			assert.Equal(t, 15, records[0].Payload)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		dir := t.TempDir()
+		l, err := OpenMessageLog(LogConf{Dir: dir, SegmentSize: 256})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		for i := 0; i < 10; i++ {
+			l.Append("orders", i)
+		}
+		assert.NoError(t, l.Close())
+
+		// simulate a record partially written by a crash
+		file, err := os.OpenFile(l.segments[len(l.segments)-1].path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		file.Write([]byte{0, 0, 1})
+		file.Close()
+
+		reopened, err := OpenMessageLog(LogConf{Dir: dir, SegmentSize: 256})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer reopened.Close()
+
+		assert.Equal(t, uint64(10), reopened.NextOffset())
+		offset, err := reopened.Append("orders", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(10), offset)
+		assert.Len(t, readAll(t, reopened, 0), 11)
+	})
+
+	t.Run("Retention by size", func(t *testing.T) {
+		l, err := OpenMessageLog(LogConf{Dir: t.TempDir(), SegmentSize: 128, MaxSize: 512})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		for i := 0; i < 100; i++ {
+			l.Append("orders", i)
+		}
+
+		assert.Greater(t, l.FirstOffset(), uint64(0))
+		records := readAll(t, l, 0)
+		assert.Equal(t, l.FirstOffset(), records[0].Offset)
+		assert.Equal(t, uint64(99), records[len(records)-1].Offset)
+	})
+
+	t.Run("Retention by age", func(t *testing.T) {
+		l, err := OpenMessageLog(LogConf{Dir: t.TempDir(), SegmentSize: 64, MaxAge: time.Hour})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		l.Append("orders", 0)
+		old := time.Now().Add(-2 * time.Hour)
+		assert.NoError(t, os.Chtimes(l.segments[0].path, old, old))
+
+		for i := 1; i < 5; i++ {
+			l.Append("orders", i)
+		}
+
+		assert.Greater(t, l.FirstOffset(), uint64(0))
+	})
+
+	t.Run("Offset at time", func(t *testing.T) {
+		l, err := OpenMessageLog(LogConf{Dir: t.TempDir()})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		l.Append("orders", 0)
+		l.Append("orders", 1)
+		time.Sleep(10 * time.Millisecond)
+		since := time.Now()
+		l.Append("orders", 2)
+
+		offset, err := l.OffsetAt(since)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), offset)
+
+		offset, err = l.OffsetAt(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), offset)
+	})
+
+	t.Run("Retention by age without appends", func(t *testing.T) {
+		l, err := OpenMessageLog(LogConf{Dir: t.TempDir(), SegmentSize: 64, MaxAge: 100 * time.Millisecond})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		l.Append("orders", 0)
+		assert.Equal(t, uint64(0), l.FirstOffset())
+
+		assert.Eventually(t, func() bool {
+			return l.FirstOffset() == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Segments removed during a read stay readable", func(t *testing.T) {
+		dir := t.TempDir()
+		l, err := OpenMessageLog(LogConf{Dir: dir, SegmentSize: 64, MaxSize: 1})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer l.Close()
+
+		for i := 0; i < 5; i++ {
+			l.Append("orders", i)
+		}
+		from, until := l.FirstOffset(), l.NextOffset()
+
+		offsets := []uint64{}
+		err = l.ReadRange(from, until, func(record *LogRecord) error {
+			if len(offsets) == 0 {
+				// the retention removes the segments being read
+				for i := 5; i < 10; i++ {
+					l.Append("orders", i)
+				}
+				assert.Greater(t, l.FirstOffset(), from)
+			}
+			offsets = append(offsets, record.Offset)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, offsets, int(until-from))
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, len(l.segments))
+	})
+
+	t.Run("Offset at time skips the older segments", func(t *testing.T) {
+		dir := t.TempDir()
+		l, err := OpenMessageLog(LogConf{Dir: dir, SegmentSize: 64})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		l.Append("orders", 0)
+		l.Append("orders", 1)
+		time.Sleep(10 * time.Millisecond)
+		since := time.Now()
+		l.Append("orders", 2)
+
+		// the first segment can no longer be decoded, it must not be read
+		assert.NoError(t, os.WriteFile(l.segments[0].path, []byte{0, 0, 0, 2, 0xff, 0xff}, 0o644))
+
+		offset, err := l.OffsetAt(since)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), offset)
+		assert.NoError(t, l.Close())
+
+		reopened, err := OpenMessageLog(LogConf{Dir: dir, SegmentSize: 64})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer reopened.Close()
+
+		offset, err = reopened.OffsetAt(since)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), offset)
+	})
+}
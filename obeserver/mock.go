@@ -9,15 +9,16 @@ import (
 // =========================================================
 
 type MockPublisher struct {
-	PublishFunc func(req string)
+	PublishFunc func(topic string, msg any) error
 }
 
 var _ I_Publisher = (*MockPublisher)(nil)
 
-func (m *MockPublisher) Publish(req string) {
+func (m *MockPublisher) Publish(topic string, msg any) error {
 	if m.PublishFunc != nil {
-		m.PublishFunc(req)
+		return m.PublishFunc(topic, msg)
 	}
+	return nil
 }
 
 // ---------------------------------------------------------
@@ -27,6 +28,7 @@ func (m *MockPublisher) Publish(req string) {
 type MockPublisherHandler struct {
 	SubscribeFunc   func(req *common.SubscribeRequest, res *common.SubscribeResponse) error
 	UnsubscribeFunc func(req *common.SubscribeRequest, res *common.SubscribeResponse) error
+	AckFunc         func(req *common.AckRequest, res *common.AckResponse) error
 }
 
 var _ I_PublisherHandler = (*MockPublisherHandler)(nil)
@@ -47,27 +49,35 @@ func (m *MockPublisherHandler) Unsubscribe(req *common.SubscribeRequest, res *co
 	return nil
 }
 
+func (m *MockPublisherHandler) Ack(req *common.AckRequest, res *common.AckResponse) error {
+	if m.AckFunc != nil {
+		return m.AckFunc(req, res)
+	}
+	res.Ok = true
+	return nil
+}
+
 // =========================================================
 //                   SUBSCRIBER MOCKS
 // =========================================================
 
 type MockSubscriber struct {
-	SubscribeFunc   func(publisher Publisher) error
-	UnsubscribeFunc func(publisher Publisher) error
+	SubscribeFunc   func(publisher common.NetConf, topics ...string) error
+	UnsubscribeFunc func(publisher common.NetConf, topics ...string) error
 }
 
 var _ I_Subscriber = (*MockSubscriber)(nil)
 
-func (m *MockSubscriber) Subscribe(publisher Publisher) error {
+func (m *MockSubscriber) Subscribe(publisher common.NetConf, topics ...string) error {
 	if m.SubscribeFunc != nil {
-		return m.SubscribeFunc(publisher)
+		return m.SubscribeFunc(publisher, topics...)
 	}
 	return nil
 }
 
-func (m *MockSubscriber) Unsubscribe(publisher Publisher) error {
+func (m *MockSubscriber) Unsubscribe(publisher common.NetConf, topics ...string) error {
 	if m.UnsubscribeFunc != nil {
-		return m.UnsubscribeFunc(publisher)
+		return m.UnsubscribeFunc(publisher, topics...)
 	}
 	return nil
 }
@@ -77,12 +87,12 @@ func (m *MockSubscriber) Unsubscribe(publisher Publisher) error {
 // ---------------------------------------------------------
 
 type MockSubscriberHandler struct {
-	UpdateFunc func(req any, res any) error
+	UpdateFunc func(req *common.Message, res *common.UpdateResponse) error
 }
 
 var _ I_SubscriberHandler = (*MockSubscriberHandler)(nil)
 
-func (m *MockSubscriberHandler) Update(req any, res any) error {
+func (m *MockSubscriberHandler) Update(req *common.Message, res *common.UpdateResponse) error {
 	if m.UpdateFunc != nil {
 		return m.UpdateFunc(req, res)
 	}
@@ -94,10 +104,10 @@ func (m *MockSubscriberHandler) Update(req any, res any) error {
 // =========================================================
 
 type MockSubscriberClient struct {
-	UpdateFunc func(req any, res any) error
+	UpdateFunc func(req *common.Message, res *common.UpdateResponse) error
 }
 
-func (m *MockSubscriberClient) Update(req any, res any) error {
+func (m *MockSubscriberClient) Update(req *common.Message, res *common.UpdateResponse) error {
 	if m.UpdateFunc != nil {
 		return m.UpdateFunc(req, res)
 	}
@@ -106,7 +116,9 @@ func (m *MockSubscriberClient) Update(req any, res any) error {
 
 func (m *MockSubscriberClient) Call(method string, args any, reply any) error {
 	if m.UpdateFunc != nil {
-		return m.UpdateFunc(args, reply)
+		req, _ := args.(*common.Message)
+		res, _ := reply.(*common.UpdateResponse)
+		return m.UpdateFunc(req, res)
 	}
 	return nil
 }
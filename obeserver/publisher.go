@@ -1,7 +1,14 @@
 package common
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"sync"
+	"time"
 
 	"micronet/client"
 	"micronet/common"
@@ -12,7 +19,7 @@ import (
  * The basic Publisher functions
  */
 type I_Publisher interface {
-	Publish(string)
+	Publish(string, any) error
 }
 
 /**
@@ -30,28 +37,141 @@ type Publisher struct {
 type I_PublisherHandler interface {
 	Subscribe(*common.SubscribeRequest, *common.SubscribeResponse) error
 	Unsubscribe(*common.SubscribeRequest, *common.SubscribeResponse) error
+	Ack(*common.AckRequest, *common.AckResponse) error
 }
 
 /**
  * The PublisherHandler can subscribe or unsubscribe Subscribers
+ * Subscriptions are indexed by pattern in a trie to find the subscribers of a topic
  */
 type PublisherHandler struct {
 	I_PublisherHandler
+	mutex       sync.RWMutex
 	subscribers map[common.NetConf]*SubscriberClient
+	trie        *topicTrie[common.NetConf, *SubscriberClient]
+	queueConf   QueueConf
+	messageLog  *MessageLog
+	tlsConfig   *tls.Config
 }
 
 /**
- * The SubscriberClient is the client.Client used to communicate to the Subscriber
+ * The SubscriberClient is the client.Client used to communicate to the Subscriber, with the patterns it subscribed to
+ * Its messages are queued and delivered by its own goroutine, so that a slow Subscriber does not delay the others
+ * A delivered message stays in the queue's window until the Subscriber acknowledges it, and is redelivered otherwise
  */
 type SubscriberClient struct {
 	*client.Client
+	netConf common.NetConf
+	topics  map[string]struct{}
+	queue   *deliveryQueue
+	replay  *replay
+}
+
+/**
+ * The replay is the range of the MessageLog to deliver to a new SubscriberClient before the live messages
+ */
+type replay struct {
+	messageLog *MessageLog
+	from       uint64
+	until      uint64
+	patterns   []string
+}
+
+/**
+ * close stops the delivery and the connexion to the Subscriber
+ */
+func (s *SubscriberClient) close() {
+	s.queue.close()
+	s.Close()
 }
 
 /**
  * Update the subscriber
  */
-func (s *SubscriberClient) Update(req any, res any) error {
-	return s.Call("Subscriber.Update", req, res)
+func (s *SubscriberClient) Update(req *common.Message, res *common.UpdateResponse) error {
+	return s.Call("SubscriberHandler.Update", req, res)
+}
+
+/**
+ * deliver sends the queued messages until the queue is closed
+ * Each update is bounded by the acknowledgement timeout
+ */
+func (s *SubscriberClient) deliver() {
+	go s.redeliver()
+
+	if s.replay != nil {
+		err := s.replayLog()
+		if err != nil && !errors.Is(err, errQueueClosed) {
+			log.Printf("replay to %+v failed: %s", s.netConf, err)
+		}
+	}
+
+	for {
+		msg, ok := s.queue.pop()
+		if !ok {
+			return
+		}
+
+		s.send(msg)
+	}
+}
+
+/**
+ * replayLog delivers the logged messages matching the SubscriberClient's patterns, through the window
+ */
+func (s *SubscriberClient) replayLog() error {
+	patterns := newTopicTrie[string, struct{}]()
+	for _, pattern := range s.replay.patterns {
+		patterns.insert(pattern, pattern, struct{}{})
+	}
+
+	return s.replay.messageLog.ReadRange(s.replay.from, s.replay.until, func(record *LogRecord) error {
+		if len(patterns.match(record.Topic)) == 0 {
+			return nil
+		}
+
+		msg := record.toMessage()
+		if !s.queue.track(msg) {
+			return errQueueClosed
+		}
+		s.send(msg)
+
+		return nil
+	})
+}
+
+/**
+ * send updates the Subscriber, bounded by the acknowledgement timeout
+ */
+func (s *SubscriberClient) send(msg *common.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.queue.conf.AckTimeout)
+	defer cancel()
+
+	res := common.UpdateResponse{}
+	err := s.CallContext(ctx, "SubscriberHandler.Update", msg, &res)
+	if err != nil {
+		log.Println(err.Error())
+		s.queue.failed()
+	}
+}
+
+/**
+ * redeliver periodically requeues the messages that were not acknowledged in time, until the queue is closed
+ */
+func (s *SubscriberClient) redeliver() {
+	ticker := time.NewTicker(s.queue.conf.AckTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.queue.done:
+			return
+		case now := <-ticker.C:
+			if n := s.queue.requeue(now.Add(-s.queue.conf.AckTimeout)); n > 0 {
+				log.Printf("redelivering %d unacknowledged messages to %+v", n, s.netConf)
+			}
+		}
+	}
 }
 
 /**
@@ -65,7 +185,11 @@ func InitPublisher(network common.NetConf) (*Publisher, error) {
 		return nil, err
 	}
 
-	handler := &PublisherHandler{subscribers: make(map[common.NetConf]*SubscriberClient)}
+	handler := &PublisherHandler{
+		subscribers: make(map[common.NetConf]*SubscriberClient),
+		trie:        newTopicTrie[common.NetConf, *SubscriberClient](),
+		queueConf:   DefaultQueueConf,
+	}
 
 	pub := &Publisher{
 		Server:           server,
@@ -90,30 +214,157 @@ func (p *Publisher) Start() error {
 }
 
 /**
- * Subscribe will add a SubscriberClient to the list of subscribers
- * @param req is the request containig networking config to initialize SubscriberClient
+ * Stop the running server and the delivery to every subscriber
+ */
+func (p *Publisher) Stop() {
+	p.Server.Stop()
+	p.closeSubscribers()
+	p.closeMessageLog()
+}
+
+/**
+ * Shutdown gracefully stops the running server, then stops the delivery to every subscriber
+ * @param ctx bounds the time given to in-flight calls
+ * @return ctx's error if in-flight calls did not complete in time
+ */
+func (p *Publisher) Shutdown(ctx context.Context) error {
+	err := p.Server.Shutdown(ctx)
+	p.closeSubscribers()
+	p.closeMessageLog()
+
+	return err
+}
+
+/**
+ * Stats of every subscriber's queue, use Server.Stats() for the calls handled by the Publisher
+ */
+func (p *Publisher) Stats() map[common.NetConf]SubscriberStats {
+	return p.PublisherHandler.Stats()
+}
+
+/**
+ * SetQueueConf configures the queues of the subscribers subscribing from now on
+ * @param conf is the queue size and overflow policy
+ */
+func (p *PublisherHandler) SetQueueConf(conf QueueConf) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.queueConf = conf
+}
+
+/**
+ * SetClientTLSConfig makes the subscribers subscribing from now on be called over TLS
+ * Use the Server's SetTLSConfig for the subscriptions themselves
+ * @param config is the TLS configuration to call the subscribers with
+ */
+func (p *PublisherHandler) SetClientTLSConfig(config *tls.Config) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.tlsConfig = config
+}
+
+/**
+ * SetLogConf opens the MessageLog every published message is appended to, allowing Subscribers to replay them
+ * @param conf is the log's directory, segment size and retention
+ * @return an error opening the log
+ */
+func (p *PublisherHandler) SetLogConf(conf LogConf) error {
+	messageLog, err := OpenMessageLog(conf)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	previous := p.messageLog
+	p.messageLog = messageLog
+	p.mutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	return nil
+}
+
+func (p *PublisherHandler) closeMessageLog() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.messageLog != nil {
+		p.messageLog.Close()
+	}
+}
+
+/**
+ * Stats of every subscriber's queue
+ */
+func (p *PublisherHandler) Stats() map[common.NetConf]SubscriberStats {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stats := make(map[common.NetConf]SubscriberStats, len(p.subscribers))
+	for netConf, sub := range p.subscribers {
+		stats[netConf] = sub.queue.snapshot()
+	}
+
+	return stats
+}
+
+/**
+ * Subscribe will add a SubscriberClient to the list of subscribers, or add topic patterns to an existing one
+ * The SubscriberClient connects to the Subscriber on the first publication
+ * A Subscriber subscribing again, after a restart for instance, gets its unacknowledged messages redelivered
+ * A replay request replaces the previous subscription: the logged messages from the requested offset or time
+ * are delivered before the live ones
+ * @param req is the request containig networking config to initialize SubscriberClient and the topic patterns to subscribe to
  * @param res is the response that will give Ok=true if subscription was effective
- * @return a potential network error
+ * @return a potential network, invalid pattern or replay error
  */
 func (p *PublisherHandler) Subscribe(req *common.SubscribeRequest, res *common.SubscribeResponse) error {
-	_, exist := p.subscribers[req.Subscriber]
-	if exist {
-		return nil
+	for _, pattern := range req.Topics {
+		if err := ValidatePattern(pattern); err != nil {
+			return err
+		}
 	}
 
-	client, errDial := client.NewClient(req.Subscriber)
-	if errDial != nil {
-		return errDial
-	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var replaying *replay
+	if req.Replay {
+		var err error
+		replaying, err = p.newReplay(req)
+		if err != nil {
+			return err
+		}
 
-	newSubscriber := &SubscriberClient{Client: client}
+		if previous, exist := p.subscribers[req.Subscriber]; exist {
+			p.remove(req.Subscriber, previous)
+		}
+	}
 
-	p.subscribers[req.Subscriber] = newSubscriber
+	sub, exist := p.subscribers[req.Subscriber]
+	if !exist {
+		cli := client.NewLazyClient(req.Subscriber)
+		cli.SetTLSConfig(p.tlsConfig)
+		sub = &SubscriberClient{
+			Client:  cli,
+			netConf: req.Subscriber,
+			topics:  make(map[string]struct{}),
+			queue:   newDeliveryQueue(p.queueConf),
+			replay:  replaying,
+		}
+		p.subscribers[req.Subscriber] = sub
+		go sub.deliver()
+	} else {
+		sub.queue.requeue(time.Time{})
+	}
 
-	// TODO: remove dial and implement reconnection instead
-	err := p.subscribers[req.Subscriber].Dial()
-	if err != nil {
-		return err
+	for _, pattern := range req.Topics {
+		sub.topics[pattern] = struct{}{}
+		p.trie.insert(pattern, req.Subscriber, sub)
 	}
 
 	res.Ok = true
@@ -122,29 +373,184 @@ func (p *PublisherHandler) Subscribe(req *common.SubscribeRequest, res *common.S
 }
 
 /**
- * Unsubscribe will remove a SubscriberClient from the list of subscribers
- * @param req is the request containig networking config of SubscriberClient to remove
+ * Unsubscribe will remove topic patterns from a SubscriberClient, or remove it from the list of subscribers if no pattern is given
+ * @param req is the request containig networking config of SubscriberClient and the topic patterns to unsubscribe from
  * @param res is the response that will give Ok=true if unsubscription was effective
  * @return a potential network error
  */
 func (p *PublisherHandler) Unsubscribe(req *common.SubscribeRequest, res *common.SubscribeResponse) error {
-	delete(p.subscribers, req.Subscriber)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	sub, exist := p.subscribers[req.Subscriber]
+	if exist {
+		patterns := req.Topics
+		if len(patterns) == 0 {
+			for pattern := range sub.topics {
+				patterns = append(patterns, pattern)
+			}
+		}
+
+		for _, pattern := range patterns {
+			delete(sub.topics, pattern)
+			p.trie.remove(pattern, req.Subscriber)
+		}
+
+		if len(sub.topics) == 0 {
+			delete(p.subscribers, req.Subscriber)
+			sub.close()
+		}
+	}
+
 	res.Ok = true
 
 	return nil
 }
 
 /**
- * Publish will cycle through all subscribers and send them the message
- * @param req is the request
- * @param res is the response
- * @return a potential network error
+ * Ack removes an acknowledged message from the Subscriber's window
+ * @param req is the request containig networking config of SubscriberClient and the message's stream and sequence number
+ * @param res is the response that will give Ok=true if the message was waiting for its acknowledgement
+ * @return always nil, as duplicate acknowledgements are expected after a redelivery
+ */
+func (p *PublisherHandler) Ack(req *common.AckRequest, res *common.AckResponse) error {
+	p.mutex.RLock()
+	sub, exist := p.subscribers[req.Subscriber]
+	p.mutex.RUnlock()
+
+	if exist {
+		res.Ok = sub.queue.ack(req.Stream, req.Seq)
+	}
+
+	return nil
+}
+
+/**
+ * newReplay computes the range of the MessageLog requested by a Subscriber
+ * The range ends at the next offset, as the messages published from now on are delivered live
+ */
+func (p *PublisherHandler) newReplay(req *common.SubscribeRequest) (*replay, error) {
+	if p.messageLog == nil {
+		return nil, fmt.Errorf("publisher %+v has no message log to replay", req.Publisher)
+	}
+
+	from := req.FromOffset
+	if !req.FromTime.IsZero() {
+		var err error
+		from, err = p.messageLog.OffsetAt(req.FromTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &replay{
+		messageLog: p.messageLog,
+		from:       from,
+		until:      p.messageLog.NextOffset(),
+		patterns:   slices.Clone(req.Topics),
+	}, nil
+}
+
+/**
+ * disconnect removes a subscriber and every of its patterns
+ */
+func (p *PublisherHandler) disconnect(subscriber common.NetConf) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	sub, exist := p.subscribers[subscriber]
+	if !exist {
+		return
+	}
+
+	p.remove(subscriber, sub)
+}
+
+/**
+ * remove deletes a subscriber and every of its patterns, the mutex must be held
  */
-func (p *Publisher) Publish(req any, res any) {
+func (p *PublisherHandler) remove(subscriber common.NetConf, sub *SubscriberClient) {
+	for pattern := range sub.topics {
+		p.trie.remove(pattern, subscriber)
+	}
+	delete(p.subscribers, subscriber)
+	sub.close()
+}
+
+/**
+ * closeSubscribers removes every subscriber
+ */
+func (p *PublisherHandler) closeSubscribers() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
 	for _, sub := range p.subscribers {
-		err := sub.Call("SubscriberHandler.Update", &req, &res)
+		sub.close()
+	}
+	p.subscribers = make(map[common.NetConf]*SubscriberClient)
+	p.trie = newTopicTrie[common.NetConf, *SubscriberClient]()
+}
+
+/**
+ * matching lists the subscribers with a pattern matching the topic
+ */
+func (p *PublisherHandler) matching(topic string) map[common.NetConf]*SubscriberClient {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.trie.match(topic)
+}
+
+/**
+ * record appends the message to the MessageLog, if any, and lists the subscribers to deliver it to
+ * Both happen under the same lock, so that a replaying subscriber gets every message either replayed or live
+ */
+func (p *PublisherHandler) record(msg *common.Message) (map[common.NetConf]*SubscriberClient, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.messageLog != nil {
+		offset, err := p.messageLog.Append(msg.Topic, msg.Payload)
 		if err != nil {
-			log.Println(err.Error())
+			return nil, err
 		}
+		msg.Offset = offset
 	}
+
+	return p.trie.match(msg.Topic), nil
+}
+
+/**
+ * Publish will append the message to the MessageLog, if any, then queue it for every subscriber with a pattern matching the topic
+ * It returns once the message is queued, the delivery happens in the background
+ * @param topic is the message's topic, it cannot contain wildcards
+ * @param msg is the message of any type registered to gob
+ * @return an invalid topic or log error, or the common.MicronetQueueFullError of every subscriber that refused the message
+ */
+func (p *Publisher) Publish(topic string, msg any) error {
+	if err := ValidateTopic(topic); err != nil {
+		return err
+	}
+
+	req := &common.Message{Topic: topic, Payload: msg}
+	subs, errRecord := p.record(req)
+	if errRecord != nil {
+		return errRecord
+	}
+
+	errs := []error{}
+	for netConf, sub := range subs {
+		err := sub.queue.push(req, netConf)
+		if err == nil || errors.Is(err, errQueueClosed) {
+			continue
+		}
+
+		if sub.queue.conf.Overflow == OverflowDisconnect {
+			log.Printf("disconnecting subscriber %+v: %s", netConf, err)
+			p.disconnect(netConf)
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
@@ -1 +1,405 @@
 package common
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"micronet/codec"
+	"micronet/common"
+	"micronet/internal/testcert"
+	"micronet/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ListenReadynessDuration time.Duration = time.Millisecond * 100
+const ReceiveTimeout time.Duration = time.Second
+
+func startPubSub(t *testing.T, pubPort string, subPort string) (*Publisher, *Subscriber) {
+	return startPubSubCodec(t, pubPort, subPort, "")
+}
+
+func startPubSubCodec(t *testing.T, pubPort string, subPort string, codecName string) (*Publisher, *Subscriber) {
+	pubConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: pubPort, Codec: codecName}
+	subConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: subPort, Codec: codecName}
+
+	pub, errPub := InitPublisher(pubConf)
+	if !assert.NoError(t, errPub) {
+		t.FailNow()
+	}
+	go pub.Start()
+	time.Sleep(ListenReadynessDuration)
+
+	sub, errSub := InitSubscriber(subConf, pubConf)
+	if !assert.NoError(t, errSub) {
+		t.FailNow()
+	}
+	go sub.Start()
+	time.Sleep(ListenReadynessDuration)
+
+	t.Cleanup(func() {
+		pub.Stop()
+		sub.Stop()
+	})
+
+	return pub, sub
+}
+
+func receive(t *testing.T, c chan any) any {
+	select {
+	case msg := <-c:
+		return msg
+	case <-time.After(ReceiveTimeout):
+		t.Error("no message recieved")
+		return nil
+	}
+}
+
+func TestPublisherTopics(t *testing.T) {
+	t.Run("Only matching topics are delivered", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16001", "16002")
+
+		errSubscribe := sub.Subscribe(pub.NetConf, "orders", "payments")
+		if !assert.NoError(t, errSubscribe) {
+			t.FailNow()
+		}
+
+		orders := sub.Topic("orders")
+		payments := sub.Topic("payments")
+
+		go func() {
+			assert.NoError(t, pub.Publish("shipping", "ignored"))
+			assert.NoError(t, pub.Publish("orders", "order #1"))
+			assert.NoError(t, pub.Publish("payments", "payment #1"))
+		}()
+
+		assert.Equal(t, "order #1", receive(t, orders))
+		assert.Equal(t, "payment #1", receive(t, payments))
+	})
+
+	t.Run("Unsubscribe from a topic", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16003", "16004")
+
+		errSubscribe := sub.Subscribe(pub.NetConf, "orders", "payments")
+		if !assert.NoError(t, errSubscribe) {
+			t.FailNow()
+		}
+
+		errUnsubscribe := sub.Unsubscribe(pub.NetConf, "orders")
+		if !assert.NoError(t, errUnsubscribe) {
+			t.FailNow()
+		}
+
+		assert.Empty(t, pub.matching("orders"))
+		assert.Len(t, pub.matching("payments"), 1)
+
+		errUnsubscribe = sub.Unsubscribe(pub.NetConf)
+		if !assert.NoError(t, errUnsubscribe) {
+			t.FailNow()
+		}
+
+		assert.Empty(t, pub.matching("payments"))
+	})
+}
+
+func TestPublisherWildcards(t *testing.T) {
+	t.Run("Multi-level wildcard", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16005", "16006")
+
+		errSubscribe := sub.Subscribe(pub.NetConf, "orders.>")
+		if !assert.NoError(t, errSubscribe) {
+			t.FailNow()
+		}
+
+		orders := sub.Topic("orders.>")
+
+		go func() {
+			assert.NoError(t, pub.Publish("payments.eu.created", "ignored"))
+			assert.NoError(t, pub.Publish("orders.eu.created", "order #1"))
+		}()
+
+		assert.Equal(t, "order #1", receive(t, orders))
+	})
+
+	t.Run("Invalid topics", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16007", "16008")
+
+		errSubscribe := sub.Subscribe(pub.NetConf, "orders.>.created")
+		assert.Error(t, errSubscribe)
+
+		errPublish := pub.Publish("orders.*", "nope")
+		assert.ErrorAs(t, errPublish, &common.MicronetInvalidTopicError{})
+	})
+}
+
+func TestPublisherFanOut(t *testing.T) {
+	t.Run("Slow subscriber does not delay the others", func(t *testing.T) {
+		pub, slow := startPubSub(t, "16009", "16010")
+
+		fastConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "16011"}
+		fast, errFast := InitSubscriber(fastConf, pub.NetConf)
+		if !assert.NoError(t, errFast) {
+			t.FailNow()
+		}
+		go fast.Start()
+		t.Cleanup(func() { fast.Stop() })
+		time.Sleep(ListenReadynessDuration)
+
+		assert.NoError(t, slow.Subscribe(pub.NetConf, "orders"))
+		assert.NoError(t, fast.Subscribe(pub.NetConf, "orders"))
+
+		// the slow subscriber never reads its channel
+		slow.Topic("orders")
+		orders := fast.Topic("orders")
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, pub.Publish("orders", i))
+		}
+		assert.Less(t, time.Since(start), ListenReadynessDuration)
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, i, receive(t, orders))
+		}
+
+		stats := pub.Stats()
+		assert.Equal(t, uint64(5), stats[fastConf].Enqueued)
+		assert.Equal(t, 4, stats[slow.Server.NetConf].Pending)
+	})
+
+	t.Run("Disconnect on overflow", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16012", "16013")
+		pub.SetQueueConf(QueueConf{Size: 1, Overflow: OverflowDisconnect})
+
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders"))
+		sub.Topic("orders")
+
+		var errPublish error
+		for i := 0; i < 5 && errPublish == nil; i++ {
+			errPublish = pub.Publish("orders", i)
+		}
+
+		assert.ErrorAs(t, errPublish, &common.MicronetQueueFullError{})
+		assert.Empty(t, pub.Stats())
+		assert.Empty(t, pub.matching("orders"))
+	})
+}
+
+func TestPublisherAcknowledgement(t *testing.T) {
+	t.Run("Delivered messages are acknowledged", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16014", "16015")
+
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders"))
+		orders := sub.Topic("orders")
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, pub.Publish("orders", i))
+		}
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, i, receive(t, orders))
+		}
+
+		assert.Eventually(t, func() bool {
+			stats := pub.Stats()[sub.Server.NetConf]
+			return stats.Delivered == 3 && stats.Unacked == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Unacknowledged messages are redelivered", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16016", "16017")
+		pub.SetQueueConf(QueueConf{Size: 8, Window: 8, AckTimeout: 100 * time.Millisecond})
+
+		// the first acknowledgements are lost
+		lost := 2
+		var mutex sync.Mutex
+		acknowledge := sub.ack
+		sub.ack = func(msg *common.Message) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if lost > 0 {
+				lost--
+				return
+			}
+			acknowledge(msg)
+		}
+
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders"))
+		recieved := make(chan any, 16)
+		sub.Handle("orders", func(msg any) { recieved <- msg })
+
+		assert.NoError(t, pub.Publish("orders", "order #1"))
+		assert.Equal(t, "order #1", receive(t, recieved))
+
+		assert.Eventually(t, func() bool {
+			stats := pub.Stats()[sub.Server.NetConf]
+			return stats.Delivered == 1 && stats.Redelivered >= 2
+		}, 2*time.Second, 10*time.Millisecond)
+
+		// duplicates were not forwarded
+		assert.Empty(t, recieved)
+	})
+}
+
+func TestPublisherReplay(t *testing.T) {
+	t.Run("Replay from offset then live", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16018", "16019")
+		assert.NoError(t, pub.SetLogConf(LogConf{Dir: t.TempDir()}))
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, pub.Publish("orders", i))
+		}
+		assert.NoError(t, pub.Publish("payments", "ignored"))
+
+		recieved := make(chan any, 16)
+		sub.Handle("orders", func(msg any) { recieved <- msg })
+
+		assert.NoError(t, sub.SubscribeFrom(pub.NetConf, 2, "orders"))
+		assert.NoError(t, pub.Publish("orders", 5))
+
+		for i := 2; i <= 5; i++ {
+			assert.Equal(t, i, receive(t, recieved))
+		}
+	})
+
+	t.Run("Replay since time", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16020", "16021")
+		assert.NoError(t, pub.SetLogConf(LogConf{Dir: t.TempDir()}))
+
+		assert.NoError(t, pub.Publish("orders", "old"))
+		time.Sleep(10 * time.Millisecond)
+		since := time.Now()
+		assert.NoError(t, pub.Publish("orders", "recent"))
+
+		orders := sub.Topic("orders")
+		assert.NoError(t, sub.SubscribeSince(pub.NetConf, since, "orders"))
+
+		assert.Equal(t, "recent", receive(t, orders))
+	})
+
+	t.Run("Publisher without log", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16022", "16023")
+
+		assert.Error(t, sub.SubscribeFrom(pub.NetConf, 0, "orders"))
+	})
+}
+
+func TestPublisherInterceptors(t *testing.T) {
+	t.Run("Publisher and Subscriber run the server interceptors", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16028", "16029")
+
+		var mutex sync.Mutex
+		seen := make(map[string]int)
+		count := func(info *server.CallInfo, next func() error) error {
+			mutex.Lock()
+			seen[info.ServiceMethod]++
+			mutex.Unlock()
+			return next()
+		}
+		pub.Use(count)
+		sub.Server.Use(count)
+
+		orders := sub.Topic("orders")
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders"))
+		assert.NoError(t, pub.Publish("orders", 1))
+		assert.Equal(t, 1, receive(t, orders))
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		assert.Equal(t, 1, seen["PublisherHandler.Subscribe"])
+		assert.Equal(t, 1, seen["SubscriberHandler.Update"])
+	})
+
+	t.Run("Interceptor refuses subscriptions", func(t *testing.T) {
+		pub, sub := startPubSub(t, "16030", "16031")
+		pub.Use(func(info *server.CallInfo, next func() error) error {
+			if info.ServiceMethod == "PublisherHandler.Subscribe" {
+				return errors.New("subscriptions are closed")
+			}
+			return next()
+		})
+
+		assert.EqualError(t, sub.Subscribe(pub.NetConf, "orders"), "subscriptions are closed")
+	})
+}
+
+func TestPublisherCodecs(t *testing.T) {
+	for i, name := range []string{codec.Gob, codec.JSONRPC, codec.MessagePack} {
+		t.Run(name, func(t *testing.T) {
+			pub, sub := startPubSubCodec(t, strconv.Itoa(16032+2*i), strconv.Itoa(16033+2*i), name)
+
+			orders := sub.Topic("orders.*")
+			assert.NoError(t, sub.Subscribe(pub.NetConf, "orders.*"))
+
+			assert.NoError(t, pub.Publish("orders.created", "first"))
+			assert.NoError(t, pub.Publish("orders.paid", "second"))
+
+			assert.Equal(t, "first", receive(t, orders))
+			assert.Equal(t, "second", receive(t, orders))
+
+			assert.Eventually(t, func() bool {
+				return pub.Stats()[sub.ClientServer.Server.NetConf].Unacked == 0
+			}, ReceiveTimeout, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestPublisherCompression(t *testing.T) {
+	t.Run("Publications are delivered over compressed connexions", func(t *testing.T) {
+		pubConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "16038", Compression: codec.Gzip}
+		subConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "16039", Compression: codec.Gzip}
+
+		pub, errPub := InitPublisher(pubConf)
+		assert.NoError(t, errPub)
+		go pub.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		sub, errSub := InitSubscriber(subConf, pubConf)
+		assert.NoError(t, errSub)
+		go sub.Start()
+		time.Sleep(ListenReadynessDuration)
+		defer pub.Stop()
+		defer sub.Stop()
+
+		orders := sub.Topic("orders")
+		assert.NoError(t, sub.Subscribe(pubConf, "orders"))
+
+		for i := range 5 {
+			assert.NoError(t, pub.Publish("orders", strings.Repeat("order ", 100)+strconv.Itoa(i)))
+		}
+		for i := range 5 {
+			assert.Equal(t, strings.Repeat("order ", 100)+strconv.Itoa(i), receive(t, orders))
+		}
+	})
+}
+
+func TestPublisherTLS(t *testing.T) {
+	t.Run("Publications are delivered over mutual TLS", func(t *testing.T) {
+		authority := testcert.NewAuthority(t)
+		pubConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "16040"}
+		subConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: "16041"}
+
+		pub, errPub := InitPublisher(pubConf)
+		assert.NoError(t, errPub)
+		pub.SetTLSConfig(authority.ServerConfig(t))
+		pub.SetClientTLSConfig(authority.ClientConfig(t, "publisher"))
+		go pub.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		sub, errSub := InitTLSSubscriber(subConf, pubConf, authority.ServerConfig(t), authority.ClientConfig(t, "subscriber"))
+		assert.NoError(t, errSub)
+		go sub.Start()
+		time.Sleep(ListenReadynessDuration)
+		defer pub.Stop()
+		defer sub.Stop()
+
+		orders := sub.Topic("orders")
+		assert.NoError(t, sub.Subscribe(pubConf, "orders"))
+
+		assert.NoError(t, pub.Publish("orders", "secured"))
+		assert.Equal(t, "secured", receive(t, orders))
+	})
+}
@@ -0,0 +1,304 @@
+package common
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"micronet/common"
+)
+
+/**
+ * The OverflowPolicy decides what Publish does when a subscriber's queue is full
+ */
+type OverflowPolicy int
+
+const (
+	// Publish waits for room in the queue
+	OverflowBlock OverflowPolicy = iota
+	// The oldest queued message never sent is dropped to make room, the loss is not reported
+	OverflowDropOldest
+	// The published message is dropped and reported by Publish
+	OverflowDropNewest
+	// The subscriber is unsubscribed and reported by Publish
+	OverflowDisconnect
+)
+
+/**
+ * The QueueConf configures the outbound queue of every subscriber
+ * Size bounds the messages waiting to be sent, Window bounds the messages sent but not acknowledged yet
+ * A message that is not acknowledged within AckTimeout is redelivered
+ */
+type QueueConf struct {
+	Size       int
+	Overflow   OverflowPolicy
+	Window     int
+	AckTimeout time.Duration
+}
+
+/**
+ * DefaultQueueConf queues up to 1024 messages per subscriber and refuses the published message when a queue is full,
+ * so that a stalled subscriber never blocks Publish for the others and every lost message is reported by Publish
+ * Up to 64 messages can wait for their acknowledgement, for 5 seconds
+ */
+var DefaultQueueConf = QueueConf{Size: 1024, Overflow: OverflowDropNewest, Window: 64, AckTimeout: 5 * time.Second}
+
+/**
+ * The SubscriberStats count the messages going through a subscriber's queue
+ */
+type SubscriberStats struct {
+	Enqueued    uint64
+	Delivered   uint64
+	Failed      uint64
+	Dropped     uint64
+	Redelivered uint64
+	Pending     int
+	Unacked     int
+}
+
+var errQueueClosed = errors.New("queue closed")
+
+/**
+ * The deliveryQueue is a bounded FIFO of messages waiting to be delivered to one subscriber,
+ * followed by the window of messages waiting for their acknowledgement
+ * Messages are numbered from 1 within the queue's stream when they are first sent, so that dropped messages leave no gap
+ * A numbered message is never dropped: requeued messages may take the queue over its Size
+ */
+type deliveryQueue struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	conf    QueueConf
+	stream  int64
+	nextSeq uint64
+	items   []*common.Message
+	unacked map[uint64]*unackedMessage
+	closed  bool
+	done    chan struct{}
+	stats   SubscriberStats
+}
+
+type unackedMessage struct {
+	msg    *common.Message
+	sentAt time.Time
+}
+
+func newDeliveryQueue(conf QueueConf) *deliveryQueue {
+	if conf.Size < 1 {
+		conf.Size = 1
+	}
+	if conf.Window < 1 {
+		conf.Window = 1
+	}
+	if conf.AckTimeout <= 0 {
+		conf.AckTimeout = DefaultQueueConf.AckTimeout
+	}
+
+	q := &deliveryQueue{
+		conf:    conf,
+		stream:  time.Now().UnixNano(),
+		unacked: make(map[uint64]*unackedMessage),
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+
+	return q
+}
+
+/**
+ * push enqueues a copy of the message, applying the overflow policy if the queue is full
+ * @return a common.MicronetQueueFullError if the message was refused, errQueueClosed once closed
+ */
+func (q *deliveryQueue) push(msg *common.Message, subscriber common.NetConf) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for !q.closed && len(q.items) >= q.conf.Size && q.conf.Overflow == OverflowBlock {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return errQueueClosed
+	}
+
+	if len(q.items) >= q.conf.Size {
+		switch q.conf.Overflow {
+		case OverflowDropOldest:
+			oldest := slices.IndexFunc(q.items, func(queued *common.Message) bool { return queued.Seq == 0 })
+			if oldest < 0 {
+				// only requeued messages are waiting, none of them can be dropped
+				q.stats.Dropped++
+				return common.MicronetQueueFullError{NetConf: subscriber}
+			}
+			q.items = slices.Delete(q.items, oldest, oldest+1)
+			q.stats.Dropped++
+		case OverflowDropNewest:
+			q.stats.Dropped++
+			return common.MicronetQueueFullError{NetConf: subscriber}
+		default:
+			return common.MicronetQueueFullError{NetConf: subscriber}
+		}
+	}
+
+	queued := *msg
+	q.items = append(q.items, &queued)
+	q.stats.Enqueued++
+	q.cond.Broadcast()
+
+	return nil
+}
+
+/**
+ * pop waits for the next message and for room in the window, then numbers it and moves it to the window
+ * @return false once the queue is closed
+ */
+func (q *deliveryQueue) pop() (*common.Message, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for !q.closed && (len(q.items) == 0 || len(q.unacked) >= q.conf.Window) {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return nil, false
+	}
+
+	msg := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	q.number(msg)
+	q.unacked[msg.Seq] = &unackedMessage{msg: msg, sentAt: time.Now()}
+	q.cond.Broadcast()
+
+	return msg, true
+}
+
+/**
+ * track waits for room in the window, then numbers a message that does not come from the queue and moves it to the window
+ * @return false once the queue is closed
+ */
+func (q *deliveryQueue) track(msg *common.Message) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for !q.closed && len(q.unacked) >= q.conf.Window {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return false
+	}
+
+	q.number(msg)
+	q.unacked[msg.Seq] = &unackedMessage{msg: msg, sentAt: time.Now()}
+	q.stats.Enqueued++
+
+	return true
+}
+
+/**
+ * number gives the next sequence number to a message sent for the first time, the mutex must be held
+ */
+func (q *deliveryQueue) number(msg *common.Message) {
+	if msg.Seq == 0 {
+		q.nextSeq++
+		msg.Stream = q.stream
+		msg.Seq = q.nextSeq
+	}
+}
+
+/**
+ * failed records a delivery error, the message stays in the window until it is redelivered
+ */
+func (q *deliveryQueue) failed() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.stats.Failed++
+}
+
+/**
+ * ack removes an acknowledged message from the window
+ * @return false if the message was not waiting for an acknowledgement
+ */
+func (q *deliveryQueue) ack(stream int64, seq uint64) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if stream != q.stream {
+		return false
+	}
+
+	if _, exist := q.unacked[seq]; !exist {
+		return false
+	}
+
+	delete(q.unacked, seq)
+	q.stats.Delivered++
+	q.cond.Broadcast()
+
+	return true
+}
+
+/**
+ * requeue moves the unacknowledged messages sent before the deadline back to the front of the queue, in order
+ * None of them is dropped, the queue may exceed its Size until they are sent again
+ * @param deadline is the latest send time to requeue, the zero time requeues the whole window
+ * @return the number of requeued messages
+ */
+func (q *deliveryQueue) requeue(deadline time.Time) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	expired := []*common.Message{}
+	for seq, unacked := range q.unacked {
+		if deadline.IsZero() || unacked.sentAt.Before(deadline) {
+			expired = append(expired, unacked.msg)
+			delete(q.unacked, seq)
+		}
+	}
+
+	if len(expired) == 0 {
+		return 0
+	}
+
+	slices.SortFunc(expired, func(a, b *common.Message) int {
+		return cmp.Compare(a.Seq, b.Seq)
+	})
+	q.items = append(expired, q.items...)
+	q.stats.Redelivered += uint64(len(expired))
+	q.cond.Broadcast()
+
+	return len(expired)
+}
+
+/**
+ * close drops the pending messages and releases the waiting publishers and delivery goroutines
+ */
+func (q *deliveryQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.closed = true
+	q.items = nil
+	q.unacked = make(map[uint64]*unackedMessage)
+	close(q.done)
+	q.cond.Broadcast()
+}
+
+func (q *deliveryQueue) snapshot() SubscriberStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := q.stats
+	stats.Pending = len(q.items)
+	stats.Unacked = len(q.unacked)
+
+	return stats
+}
@@ -0,0 +1,190 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryQueue(t *testing.T) {
+	subscriber := common.NetConf{Ip: "127.0.0.1", Port: "16000"}
+	first := &common.Message{Topic: "orders", Payload: 1}
+	second := &common.Message{Topic: "orders", Payload: 2}
+
+	t.Run("Drop oldest", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 1, Overflow: OverflowDropOldest})
+
+		assert.NoError(t, q.push(first, subscriber))
+		assert.NoError(t, q.push(second, subscriber))
+
+		msg, ok := q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, second.Payload, msg.Payload)
+		assert.Equal(t, uint64(1), q.snapshot().Dropped)
+	})
+
+	t.Run("Drop newest", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 1, Overflow: OverflowDropNewest})
+
+		assert.NoError(t, q.push(first, subscriber))
+		assert.ErrorAs(t, q.push(second, subscriber), &common.MicronetQueueFullError{})
+
+		msg, ok := q.pop()
+		assert.True(t, ok)
+		assert.Equal(t, first.Payload, msg.Payload)
+		assert.Equal(t, uint64(1), q.snapshot().Dropped)
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 1, Overflow: OverflowDisconnect})
+
+		assert.NoError(t, q.push(first, subscriber))
+		assert.ErrorAs(t, q.push(second, subscriber), &common.MicronetQueueFullError{})
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 1, Overflow: OverflowBlock})
+		assert.NoError(t, q.push(first, subscriber))
+
+		pushed := make(chan error, 1)
+		go func() {
+			pushed <- q.push(second, subscriber)
+		}()
+
+		select {
+		case <-pushed:
+			t.Fatal("push did not block on a full queue")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		msg, _ := q.pop()
+		assert.Equal(t, first.Payload, msg.Payload)
+		assert.NoError(t, <-pushed)
+
+		stats := q.snapshot()
+		assert.Equal(t, uint64(2), stats.Enqueued)
+		assert.Equal(t, 1, stats.Pending)
+	})
+
+	t.Run("Close releases waiters", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 1, Overflow: OverflowBlock})
+		assert.NoError(t, q.push(first, subscriber))
+
+		pushed := make(chan error, 1)
+		go func() {
+			pushed <- q.push(second, subscriber)
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		q.close()
+		assert.ErrorIs(t, <-pushed, errQueueClosed)
+
+		_, ok := q.pop()
+		assert.False(t, ok)
+	})
+}
+
+func TestDeliveryQueueAcknowledgement(t *testing.T) {
+	subscriber := common.NetConf{Ip: "127.0.0.1", Port: "16000"}
+
+	t.Run("Window", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 4, Window: 1, AckTimeout: time.Second})
+		assert.NoError(t, q.push(&common.Message{Payload: 1}, subscriber))
+		assert.NoError(t, q.push(&common.Message{Payload: 2}, subscriber))
+
+		first, _ := q.pop()
+		assert.Equal(t, uint64(1), first.Seq)
+
+		popped := make(chan *common.Message, 1)
+		go func() {
+			msg, _ := q.pop()
+			popped <- msg
+		}()
+
+		select {
+		case <-popped:
+			t.Fatal("pop did not wait for room in the window")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		assert.False(t, q.ack(first.Stream+1, first.Seq))
+		assert.True(t, q.ack(first.Stream, first.Seq))
+		assert.False(t, q.ack(first.Stream, first.Seq))
+
+		second := <-popped
+		assert.Equal(t, uint64(2), second.Seq)
+		assert.Equal(t, uint64(1), q.snapshot().Delivered)
+	})
+
+	t.Run("Requeue", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 4, Window: 4, AckTimeout: time.Second})
+		for i := 1; i <= 3; i++ {
+			assert.NoError(t, q.push(&common.Message{Payload: i}, subscriber))
+		}
+
+		first, _ := q.pop()
+		second, _ := q.pop()
+		assert.True(t, q.ack(second.Stream, second.Seq))
+
+		assert.Equal(t, 0, q.requeue(time.Now().Add(-time.Minute)))
+		assert.Equal(t, 1, q.requeue(time.Time{}))
+
+		redelivered, _ := q.pop()
+		assert.Equal(t, first.Seq, redelivered.Seq)
+		third, _ := q.pop()
+		assert.Equal(t, uint64(3), third.Seq)
+
+		stats := q.snapshot()
+		assert.Equal(t, uint64(1), stats.Redelivered)
+		assert.Equal(t, 2, stats.Unacked)
+	})
+
+	t.Run("Requeue never drops unacknowledged messages", func(t *testing.T) {
+		for _, overflow := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowDropNewest} {
+			q := newDeliveryQueue(QueueConf{Size: 2, Overflow: overflow, Window: 4, AckTimeout: time.Second})
+			for i := 1; i <= 2; i++ {
+				assert.NoError(t, q.push(&common.Message{Payload: i}, subscriber))
+			}
+			q.pop()
+			q.pop()
+			for i := 3; i <= 4; i++ {
+				assert.NoError(t, q.push(&common.Message{Payload: i}, subscriber))
+			}
+
+			assert.Equal(t, 2, q.requeue(time.Time{}))
+
+			stats := q.snapshot()
+			assert.Equal(t, 4, stats.Pending)
+			assert.Equal(t, uint64(0), stats.Dropped)
+
+			payloads := []any{}
+			for i := 0; i < 4; i++ {
+				msg, _ := q.pop()
+				payloads = append(payloads, msg.Payload)
+			}
+			assert.Equal(t, []any{1, 2, 3, 4}, payloads)
+		}
+	})
+
+	t.Run("Drop oldest spares the requeued messages", func(t *testing.T) {
+		q := newDeliveryQueue(QueueConf{Size: 2, Overflow: OverflowDropOldest, Window: 2, AckTimeout: time.Second})
+		assert.NoError(t, q.push(&common.Message{Payload: 1}, subscriber))
+		q.pop()
+		assert.NoError(t, q.push(&common.Message{Payload: 2}, subscriber))
+		assert.Equal(t, 1, q.requeue(time.Time{}))
+
+		assert.NoError(t, q.push(&common.Message{Payload: 3}, subscriber))
+		assert.Equal(t, uint64(1), q.snapshot().Dropped)
+
+		redelivered, _ := q.pop()
+		assert.Equal(t, 1, redelivered.Payload)
+		next, _ := q.pop()
+		assert.Equal(t, 3, next.Payload)
+
+		q.requeue(time.Time{})
+		assert.ErrorAs(t, q.push(&common.Message{Payload: 4}, subscriber), &common.MicronetQueueFullError{})
+	})
+}
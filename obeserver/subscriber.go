@@ -1,7 +1,12 @@
 package common
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"micronet/clientServer"
 	"micronet/common"
@@ -11,8 +16,8 @@ import (
  * The basic Subscriber functions
  */
 type I_Subscriber interface {
-	Subscribe(Publisher) error
-	Unsubscribe(Publisher) error
+	Subscribe(common.NetConf, ...string) error
+	Unsubscribe(common.NetConf, ...string) error
 }
 
 /**
@@ -28,15 +33,68 @@ type Subscriber struct {
  * The basic SubscriberHandler functions
  */
 type I_SubscriberHandler interface {
-	Update(any, any) error
+	Update(*common.Message, *common.UpdateResponse) error
 }
 
+var errSubscriberClosed = errors.New("subscriber is closed")
+
 /**
- * The SubscriberHandler has a channel to forward the update message
+ * The SubscriberHandler forwards the update message to the callbacks and channels of the matching patterns, or to the default channel
+ * Sequenced messages are acknowledged once forwarded, and redelivered duplicates are only acknowledged again
+ * A duplicate of a message that is still being forwarded is refused, so that the publisher keeps it until it is acknowledged
  */
 type SubscriberHandler struct {
 	I_SubscriberHandler
-	msgChan chan any
+	msgChan   chan any
+	mutex     sync.RWMutex
+	topics    map[string]chan any
+	callbacks map[string]func(*common.Message) error
+	patterns  *topicTrie[string, struct{}]
+	closing   chan struct{}
+	updates   sync.WaitGroup
+	seqMutex  sync.Mutex
+	streams   map[int64]*sequenceTracker
+	ack       func(*common.Message)
+}
+
+/**
+ * The sequenceTracker remembers the sequence numbers recieved from a publisher's stream
+ * Every number up to low was recieved, seen holds the ones recieved above it and inFlight the ones being forwarded
+ */
+type sequenceTracker struct {
+	low      uint64
+	seen     map[uint64]struct{}
+	inFlight map[uint64]struct{}
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{seen: make(map[uint64]struct{}), inFlight: make(map[uint64]struct{})}
+}
+
+/**
+ * recieved tells if a sequence number was already recieved
+ */
+func (t *sequenceTracker) recieved(seq uint64) bool {
+	_, exist := t.seen[seq]
+	return exist || seq <= t.low
+}
+
+/**
+ * record records a recieved sequence number
+ */
+func (t *sequenceTracker) record(seq uint64) {
+	if t.recieved(seq) {
+		return
+	}
+	t.seen[seq] = struct{}{}
+
+	for {
+		if _, exist := t.seen[t.low+1]; !exist {
+			break
+		}
+		delete(t.seen, t.low+1)
+		t.low++
+	}
 }
 
 /**
@@ -51,12 +109,20 @@ func InitSubscriber(selfNetwork common.NetConf, remoteNetwork common.NetConf) (*
 		return nil, err
 	}
 
-	handler := &SubscriberHandler{msgChan: make(chan any)}
+	handler := &SubscriberHandler{
+		msgChan:   make(chan any),
+		topics:    make(map[string]chan any),
+		callbacks: make(map[string]func(*common.Message) error),
+		patterns:  newTopicTrie[string, struct{}](),
+		closing:   make(chan struct{}),
+		streams:   make(map[int64]*sequenceTracker),
+	}
 
 	subscriber := &Subscriber{
 		ClientServer:      clientServer,
 		SubscriberHandler: handler,
 	}
+	handler.ack = subscriber.acknowledge
 
 	err = subscriber.Register(handler)
 	if err != nil {
@@ -66,6 +132,26 @@ func InitSubscriber(selfNetwork common.NetConf, remoteNetwork common.NetConf) (*
 	return subscriber, nil
 }
 
+/**
+ * InitTLSSubscriber creates a Subscriber secured by TLS on both sides, before it first calls the Publisher
+ * The Publisher calls the Subscriber back with the configuration given to its SetClientTLSConfig
+ * @param selfNetwork is the server's network config
+ * @param remoteNetwork is the remote server's network config
+ * @param serverConfig is the TLS configuration of the server recieving the publications
+ * @param clientConfig is the TLS configuration to call the Publisher with
+ * @return the initialized Subscriber or error
+ */
+func InitTLSSubscriber(selfNetwork common.NetConf, remoteNetwork common.NetConf, serverConfig *tls.Config, clientConfig *tls.Config) (*Subscriber, error) {
+	subscriber, err := InitSubscriber(selfNetwork, remoteNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriber.SetTLSConfig(serverConfig, clientConfig)
+
+	return subscriber, nil
+}
+
 /**
  * Start the ClientServer that was initialized with a netork config
  * You might consider starting the server in a goroutine
@@ -76,32 +162,73 @@ func (s *Subscriber) Start() error {
 }
 
 /**
- * Subscribe to the desired publisher
+ * Subscribe to topics of the desired publisher
  * @param publisher is the target publisher
+ * @param topics are the topic patterns to subscribe to, wildcards are allowed
+ * @return potential networking or subscription errors
+ */
+func (s *Subscriber) Subscribe(publisher common.NetConf, topics ...string) error {
+	return s.subscribe(&common.SubscribeRequest{Subscriber: s.Server.NetConf, Publisher: publisher, Topics: topics})
+}
+
+/**
+ * SubscribeFrom replays the messages logged by the desired publisher from an offset, then recieves the live ones
+ * It replaces any previous subscription to the publisher
+ * @param publisher is the target publisher, it must have a MessageLog
+ * @param offset is the first message to replay
+ * @param topics are the topic patterns to subscribe to, wildcards are allowed
  * @return potential networking or subscription errors
  */
-func (s *Subscriber) Subscribe(publisher common.NetConf) error {
-	req := common.SubscribeRequest{Subscriber: s.Server.NetConf}
+func (s *Subscriber) SubscribeFrom(publisher common.NetConf, offset uint64, topics ...string) error {
+	return s.subscribe(&common.SubscribeRequest{
+		Subscriber: s.Server.NetConf,
+		Publisher:  publisher,
+		Topics:     topics,
+		Replay:     true,
+		FromOffset: offset,
+	})
+}
+
+/**
+ * SubscribeSince replays the messages logged by the desired publisher since a time, then recieves the live ones
+ * It replaces any previous subscription to the publisher
+ * @param publisher is the target publisher, it must have a MessageLog
+ * @param since is the publication time of the first message to replay
+ * @param topics are the topic patterns to subscribe to, wildcards are allowed
+ * @return potential networking or subscription errors
+ */
+func (s *Subscriber) SubscribeSince(publisher common.NetConf, since time.Time, topics ...string) error {
+	return s.subscribe(&common.SubscribeRequest{
+		Subscriber: s.Server.NetConf,
+		Publisher:  publisher,
+		Topics:     topics,
+		Replay:     true,
+		FromTime:   since,
+	})
+}
+
+func (s *Subscriber) subscribe(req *common.SubscribeRequest) error {
 	res := common.SubscribeResponse{}
-	err := s.Call("PublisherHandler.Subscribe", &req, &res)
+	err := s.Call("PublisherHandler.Subscribe", req, &res)
 	if err != nil {
 		return err
 	}
 
 	if !res.Ok {
-		return fmt.Errorf("publisher %+v could not subscribe %+v", publisher, s.Server.NetConf)
+		return fmt.Errorf("publisher %+v could not subscribe %+v", req.Publisher, s.Server.NetConf)
 	}
 
 	return nil
 }
 
 /**
- * Unsubscribe from the desired publisher
+ * Unsubscribe from topics of the desired publisher
  * @param publisher is the target publisher
+ * @param topics are the topics to unsubscribe from, every topic if empty
  * @return potential networking or unsubscription errors
  */
-func (s *Subscriber) Unsubscribe(publisher common.NetConf) error {
-	req := common.SubscribeRequest{Subscriber: s.Server.NetConf}
+func (s *Subscriber) Unsubscribe(publisher common.NetConf, topics ...string) error {
+	req := common.SubscribeRequest{Subscriber: s.Server.NetConf, Publisher: publisher, Topics: topics}
 	res := common.SubscribeResponse{}
 	err := s.Call("PublisherHandler.Unsubscribe", &req, &res)
 	if err != nil {
@@ -116,26 +243,240 @@ func (s *Subscriber) Unsubscribe(publisher common.NetConf) error {
 }
 
 /**
- * Update will forward the incoming request data to the message channel
+ * acknowledge sends the acknowledgement of a message to the publisher in the background
+ * A lost acknowledgement only causes a redelivery
+ */
+func (s *Subscriber) acknowledge(msg *common.Message) {
+	req := common.AckRequest{Subscriber: s.Server.NetConf, Stream: msg.Stream, Seq: msg.Seq}
+
+	go func() {
+		res := common.AckResponse{}
+		err := s.Call("PublisherHandler.Ack", &req, &res)
+		if err != nil {
+			log.Printf("acknowledgement of message %d failed: %s", req.Seq, err)
+		}
+	}()
+}
+
+/**
+ * beginDelivery marks a sequenced message as being forwarded
+ * @return true if the message was already recieved, an error if it is still being forwarded
  */
-func (s *SubscriberHandler) Update(req *any, res *any) error {
-	s.msgChan <- *req
+func (s *SubscriberHandler) beginDelivery(msg *common.Message) (bool, error) {
+	if msg.Seq == 0 {
+		return false, nil
+	}
+
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+
+	tracker, exist := s.streams[msg.Stream]
+	if !exist {
+		tracker = newSequenceTracker()
+		s.streams[msg.Stream] = tracker
+	}
+
+	if tracker.recieved(msg.Seq) {
+		return true, nil
+	}
+	if _, exist := tracker.inFlight[msg.Seq]; exist {
+		return false, fmt.Errorf("message %d is already being forwarded", msg.Seq)
+	}
+	tracker.inFlight[msg.Seq] = struct{}{}
+
+	return false, nil
+}
+
+/**
+ * endDelivery records a forwarded sequenced message, or forgets a failed one so that its redelivery is accepted
+ */
+func (s *SubscriberHandler) endDelivery(msg *common.Message, forwarded bool) {
+	if msg.Seq == 0 {
+		return
+	}
+
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+
+	tracker := s.streams[msg.Stream]
+	delete(tracker.inFlight, msg.Seq)
+	if forwarded {
+		tracker.record(msg.Seq)
+	}
+}
+
+/**
+ * Update will forward the incoming message to the callback or channel of every matching pattern, then acknowledge it
+ * Messages without matching callback nor channel are forwarded to the default channel
+ * A message that could not be forwarded is not acknowledged, so that the publisher redelivers it
+ */
+func (s *SubscriberHandler) Update(req *common.Message, res *common.UpdateResponse) error {
+	callbacks := []func(*common.Message) error{}
+	channels := []chan any{}
+
+	s.mutex.RLock()
+	select {
+	case <-s.closing:
+		s.mutex.RUnlock()
+		return errSubscriberClosed
+	default:
+	}
+	s.updates.Add(1)
+	defer s.updates.Done()
+
+	duplicate, errDelivery := s.beginDelivery(req)
+	if errDelivery != nil {
+		s.mutex.RUnlock()
+		return errDelivery
+	}
+	if duplicate {
+		s.mutex.RUnlock()
+		s.acknowledge(req)
+		res.Ok = true
+		return nil
+	}
+
+	for pattern := range s.patterns.match(req.Topic) {
+		if callback, exist := s.callbacks[pattern]; exist {
+			callbacks = append(callbacks, callback)
+		} else if topicChan, exist := s.topics[pattern]; exist {
+			channels = append(channels, topicChan)
+		}
+	}
+	s.mutex.RUnlock()
+
+	err := s.forward(req, callbacks, channels)
+	s.endDelivery(req, err == nil)
+	if err != nil {
+		return err
+	}
+	s.acknowledge(req)
+
+	res.Ok = true
+
+	return nil
+}
+
+/**
+ * forward calls the callbacks then sends the payload to the channels, or to the default channel if there are none
+ * @return the first callback error, or errSubscriberClosed if the subscriber closed before a channel recieved the payload
+ */
+func (s *SubscriberHandler) forward(req *common.Message, callbacks []func(*common.Message) error, channels []chan any) error {
+	for _, callback := range callbacks {
+		if err := callback(req); err != nil {
+			return err
+		}
+	}
+	if len(callbacks) == 0 && len(channels) == 0 {
+		channels = append(channels, s.msgChan)
+	}
+	for _, topicChan := range channels {
+		select {
+		case topicChan <- req.Payload:
+		case <-s.closing:
+			return errSubscriberClosed
+		}
+	}
 
 	return nil
 }
 
+/**
+ * acknowledge forwards the acknowledgement of a sequenced message to the Subscriber
+ */
+func (s *SubscriberHandler) acknowledge(msg *common.Message) {
+	if msg.Seq != 0 && s.ack != nil {
+		s.ack(msg)
+	}
+}
+
 /**
  * Message channel getter
+ * It recieves the messages without matching callback nor channel
  */
 func (s *Subscriber) Chan() chan any {
 	return s.msgChan
 }
 
 /**
- * Close the running server
+ * Topic channel getter, the channel is created on first use
+ * @param pattern is the topic pattern to recieve messages from, wildcards are allowed
+ */
+func (s *Subscriber) Topic(pattern string) chan any {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	topicChan, exist := s.topics[pattern]
+	if !exist {
+		topicChan = make(chan any)
+		s.topics[pattern] = topicChan
+		s.patterns.insert(pattern, pattern, struct{}{})
+	}
+
+	return topicChan
+}
+
+/**
+ * Handle sets the callback called for every message matching a pattern, instead of forwarding it to a channel
+ * @param pattern is the topic pattern to recieve messages from, wildcards are allowed
+ * @param callback is called with the message, a nil callback removes the previous one
+ */
+func (s *Subscriber) Handle(pattern string, callback func(any)) {
+	if callback == nil {
+		s.handle(pattern, nil)
+		return
+	}
+
+	s.handle(pattern, func(req *common.Message) error {
+		callback(req.Payload)
+		return nil
+	})
+}
+
+/**
+ * handle sets the callback of a pattern, a callback error leaves the message unacknowledged
+ */
+func (s *Subscriber) handle(pattern string, callback func(*common.Message) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if callback == nil {
+		delete(s.callbacks, pattern)
+		if _, exist := s.topics[pattern]; !exist {
+			s.patterns.remove(pattern, pattern)
+		}
+		return
+	}
+
+	s.callbacks[pattern] = callback
+	s.patterns.insert(pattern, pattern, struct{}{})
+}
+
+/**
+ * Close the running server, then close every channel once the pending updates are released
  * Same as Stop()
  */
 func (s *Subscriber) Close() error {
+	s.mutex.Lock()
+	select {
+	case <-s.closing:
+		s.mutex.Unlock()
+		return s.ClientServer.Close()
+	default:
+	}
+	close(s.closing)
+	s.mutex.Unlock()
+	s.updates.Wait()
+
+	s.mutex.Lock()
+	for _, topicChan := range s.topics {
+		close(topicChan)
+	}
+	s.topics = make(map[string]chan any)
+	s.callbacks = make(map[string]func(*common.Message) error)
+	s.patterns = newTopicTrie[string, struct{}]()
+	s.mutex.Unlock()
+
 	close(s.msgChan)
 	return s.ClientServer.Close()
 }
@@ -1,2 +1,157 @@
 package common
 
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSubscriberHandler() *Subscriber {
+	return &Subscriber{SubscriberHandler: &SubscriberHandler{
+		msgChan:   make(chan any, 1),
+		topics:    make(map[string]chan any),
+		callbacks: make(map[string]func(*common.Message) error),
+		patterns:  newTopicTrie[string, struct{}](),
+		closing:   make(chan struct{}),
+		streams:   make(map[int64]*sequenceTracker),
+	}}
+}
+
+func TestSubscriberUpdate(t *testing.T) {
+	t.Run("Callback", func(t *testing.T) {
+		sub := newTestSubscriberHandler()
+
+		var recieved any
+		sub.Handle("orders", func(msg any) { recieved = msg })
+
+		res := common.UpdateResponse{}
+		err := sub.Update(&common.Message{Topic: "orders", Payload: "order #1"}, &res)
+
+		assert.NoError(t, err)
+		assert.True(t, res.Ok)
+		assert.Equal(t, "order #1", recieved)
+	})
+
+	t.Run("Topic channel", func(t *testing.T) {
+		sub := newTestSubscriberHandler()
+		orders := sub.Topic("orders")
+
+		go func() {
+			res := common.UpdateResponse{}
+			sub.Update(&common.Message{Topic: "orders", Payload: "order #1"}, &res)
+		}()
+
+		assert.Equal(t, "order #1", <-orders)
+	})
+
+	t.Run("Default channel", func(t *testing.T) {
+		sub := newTestSubscriberHandler()
+
+		res := common.UpdateResponse{}
+		err := sub.Update(&common.Message{Topic: "orders", Payload: "order #1"}, &res)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "order #1", <-sub.Chan())
+	})
+}
+
+func TestSubscriberWildcardUpdate(t *testing.T) {
+	sub := newTestSubscriberHandler()
+
+	var exact, wildcard []any
+	sub.Handle("orders.eu.created", func(msg any) { exact = append(exact, msg) })
+	sub.Handle("orders.>", func(msg any) { wildcard = append(wildcard, msg) })
+
+	res := common.UpdateResponse{}
+	assert.NoError(t, sub.Update(&common.Message{Topic: "orders.eu.created", Payload: 1}, &res))
+	assert.NoError(t, sub.Update(&common.Message{Topic: "orders.us.deleted", Payload: 2}, &res))
+
+	assert.Equal(t, []any{1}, exact)
+	assert.Equal(t, []any{1, 2}, wildcard)
+}
+
+func TestSubscriberDeduplication(t *testing.T) {
+	sub := newTestSubscriberHandler()
+
+	acked := []uint64{}
+	sub.ack = func(msg *common.Message) { acked = append(acked, msg.Seq) }
+
+	recieved := []any{}
+	sub.Handle("orders", func(msg any) { recieved = append(recieved, msg) })
+
+	for _, seq := range []uint64{1, 3, 1, 2, 3, 4} {
+		res := common.UpdateResponse{}
+		err := sub.Update(&common.Message{Topic: "orders", Payload: seq, Stream: 42, Seq: seq}, &res)
+		assert.NoError(t, err)
+		assert.True(t, res.Ok)
+	}
+
+	assert.Equal(t, []any{uint64(1), uint64(3), uint64(2), uint64(4)}, recieved)
+	assert.Equal(t, []uint64{1, 3, 1, 2, 3, 4}, acked)
+	assert.Equal(t, uint64(4), sub.streams[42].low)
+	assert.Empty(t, sub.streams[42].seen)
+
+	// a new stream, after a publisher restart, starts over
+	res := common.UpdateResponse{}
+	assert.NoError(t, sub.Update(&common.Message{Topic: "orders", Payload: "restarted", Stream: 43, Seq: 1}, &res))
+	assert.Equal(t, "restarted", recieved[len(recieved)-1])
+}
+
+func TestSubscriberDeduplicationInFlight(t *testing.T) {
+	t.Run("Duplicate of a message being forwarded is refused", func(t *testing.T) {
+		sub := newTestSubscriberHandler()
+		orders := sub.Topic("orders")
+
+		acked := make(chan uint64, 2)
+		sub.ack = func(msg *common.Message) { acked <- msg.Seq }
+
+		msg := &common.Message{Topic: "orders", Payload: "order #1", Stream: 42, Seq: 1}
+		forwarded := make(chan error, 1)
+		go func() {
+			forwarded <- sub.Update(msg, &common.UpdateResponse{})
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		res := common.UpdateResponse{}
+		assert.Error(t, sub.Update(msg, &res))
+		assert.False(t, res.Ok)
+		assert.Empty(t, acked)
+
+		assert.Equal(t, "order #1", <-orders)
+		assert.NoError(t, <-forwarded)
+		assert.Equal(t, uint64(1), <-acked)
+
+		// once forwarded, the duplicate is acknowledged again
+		assert.NoError(t, sub.Update(msg, &res))
+		assert.True(t, res.Ok)
+		assert.Equal(t, uint64(1), <-acked)
+	})
+
+	t.Run("Message that failed is forwarded again", func(t *testing.T) {
+		sub := newTestSubscriberHandler()
+
+		acked := []uint64{}
+		sub.ack = func(msg *common.Message) { acked = append(acked, msg.Seq) }
+
+		calls := 0
+		sub.handle("orders", func(msg *common.Message) error {
+			calls++
+			if calls == 1 {
+				return errors.New("busy")
+			}
+			return nil
+		})
+
+		msg := &common.Message{Topic: "orders", Payload: "order #1", Stream: 42, Seq: 1}
+		assert.EqualError(t, sub.Update(msg, &common.UpdateResponse{}), "busy")
+		assert.Empty(t, acked)
+
+		assert.NoError(t, sub.Update(msg, &common.UpdateResponse{}))
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, []uint64{1}, acked)
+	})
+}
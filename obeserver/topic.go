@@ -0,0 +1,158 @@
+package common
+
+import (
+	"strings"
+
+	"micronet/common"
+)
+
+/**
+ * Topics are hierarchical names whose levels are separated by dots, like "orders.eu.created"
+ * Subscription patterns can use wildcards:
+ * "*" matches exactly one level, "orders.*.created"
+ * ">" or "#" matches one or more trailing levels, "orders.>"
+ */
+const (
+	TopicSeparator      string = "."
+	SingleLevelWildcard string = "*"
+	MultiLevelWildcard  string = ">"
+	multiLevelAlias     string = "#"
+)
+
+/**
+ * ValidateTopic checks a topic messages can be published to: non empty levels and no wildcard
+ * @param topic is the topic to check
+ * @return a common.MicronetInvalidTopicError if the topic is invalid
+ */
+func ValidateTopic(topic string) error {
+	for _, level := range strings.Split(topic, TopicSeparator) {
+		if level == "" || level == SingleLevelWildcard || level == MultiLevelWildcard || level == multiLevelAlias {
+			return common.MicronetInvalidTopicError{Topic: topic}
+		}
+	}
+
+	return nil
+}
+
+/**
+ * ValidatePattern checks a subscription pattern: non empty levels and a multi-level wildcard only as last level
+ * @param pattern is the pattern to check
+ * @return a common.MicronetInvalidTopicError if the pattern is invalid
+ */
+func ValidatePattern(pattern string) error {
+	levels := strings.Split(pattern, TopicSeparator)
+	for i, level := range levels {
+		if level == "" {
+			return common.MicronetInvalidTopicError{Topic: pattern}
+		}
+
+		if (level == MultiLevelWildcard || level == multiLevelAlias) && i != len(levels)-1 {
+			return common.MicronetInvalidTopicError{Topic: pattern}
+		}
+	}
+
+	return nil
+}
+
+/**
+ * patternLevels splits a pattern, normalizing the multi-level wildcard alias
+ */
+func patternLevels(pattern string) []string {
+	levels := strings.Split(pattern, TopicSeparator)
+	if last := len(levels) - 1; levels[last] == multiLevelAlias {
+		levels[last] = MultiLevelWildcard
+	}
+
+	return levels
+}
+
+/**
+ * The topicTrie indexes values by subscription pattern, so that the values matching a topic are found
+ * without scanning every pattern. It is not safe for concurrent use.
+ */
+type topicTrie[K comparable, V any] struct {
+	root *topicNode[K, V]
+}
+
+type topicNode[K comparable, V any] struct {
+	children map[string]*topicNode[K, V]
+	values   map[K]V
+}
+
+func newTopicTrie[K comparable, V any]() *topicTrie[K, V] {
+	return &topicTrie[K, V]{root: newTopicNode[K, V]()}
+}
+
+func newTopicNode[K comparable, V any]() *topicNode[K, V] {
+	return &topicNode[K, V]{
+		children: make(map[string]*topicNode[K, V]),
+		values:   make(map[K]V),
+	}
+}
+
+/**
+ * insert adds or replaces the value of key under a pattern
+ */
+func (t *topicTrie[K, V]) insert(pattern string, key K, value V) {
+	node := t.root
+	for _, level := range patternLevels(pattern) {
+		child, exist := node.children[level]
+		if !exist {
+			child = newTopicNode[K, V]()
+			node.children[level] = child
+		}
+		node = child
+	}
+
+	node.values[key] = value
+}
+
+/**
+ * remove deletes the value of key under a pattern, pruning the emptied nodes
+ */
+func (t *topicTrie[K, V]) remove(pattern string, key K) {
+	t.root.remove(patternLevels(pattern), key)
+}
+
+func (n *topicNode[K, V]) remove(levels []string, key K) bool {
+	if len(levels) == 0 {
+		delete(n.values, key)
+	} else if child, exist := n.children[levels[0]]; exist && child.remove(levels[1:], key) {
+		delete(n.children, levels[0])
+	}
+
+	return len(n.values) == 0 && len(n.children) == 0
+}
+
+/**
+ * match returns the values whose pattern matches the topic, once per key
+ */
+func (t *topicTrie[K, V]) match(topic string) map[K]V {
+	matches := make(map[K]V)
+	t.root.match(strings.Split(topic, TopicSeparator), matches)
+
+	return matches
+}
+
+func (n *topicNode[K, V]) match(levels []string, matches map[K]V) {
+	if len(levels) == 0 {
+		for key, value := range n.values {
+			matches[key] = value
+		}
+		return
+	}
+
+	if child, exist := n.children[MultiLevelWildcard]; exist {
+		for key, value := range child.values {
+			matches[key] = value
+		}
+	}
+
+	if child, exist := n.children[SingleLevelWildcard]; exist {
+		child.match(levels[1:], matches)
+	}
+
+	if child, exist := n.children[levels[0]]; exist {
+		child.match(levels[1:], matches)
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopicValidation(t *testing.T) {
+	assert.NoError(t, ValidateTopic("orders.eu.created"))
+	assert.Error(t, ValidateTopic("orders..created"))
+	assert.Error(t, ValidateTopic("orders.*"))
+	assert.Error(t, ValidateTopic("orders.>"))
+
+	assert.NoError(t, ValidatePattern("orders.*.created"))
+	assert.NoError(t, ValidatePattern("orders.>"))
+	assert.NoError(t, ValidatePattern("orders.#"))
+	assert.Error(t, ValidatePattern("orders.>.created"))
+	assert.Error(t, ValidatePattern(""))
+}
+
+func TestTopicTrie(t *testing.T) {
+	trie := newTopicTrie[string, string]()
+	for _, pattern := range []string{"orders.eu.created", "orders.*.created", "orders.>", "orders.#", "*", "payments.>"} {
+		trie.insert(pattern, pattern, pattern)
+	}
+
+	cases := map[string][]string{
+		"orders.eu.created": {"orders.eu.created", "orders.*.created", "orders.>", "orders.#"},
+		"orders.us.created": {"orders.*.created", "orders.>", "orders.#"},
+		"orders.eu":         {"orders.>", "orders.#"},
+		"orders":            {"*"},
+		"payments":          {"*"},
+		"shipping.eu":       {},
+	}
+
+	for topic, expected := range cases {
+		matches := []string{}
+		for pattern := range trie.match(topic) {
+			matches = append(matches, pattern)
+		}
+		assert.ElementsMatch(t, expected, matches, topic)
+	}
+
+	trie.remove("orders.*.created", "orders.*.created")
+	trie.remove("orders.eu.created", "orders.eu.created")
+	assert.NotContains(t, trie.match("orders.eu.created"), "orders.*.created")
+	assert.NotContains(t, trie.root.children["orders"].children, "eu")
+}
@@ -0,0 +1,210 @@
+package common
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"micronet/common"
+)
+
+/**
+ * registerType registers T to gob, so that it can travel as a message payload
+ * Interface types cannot be registered and are skipped
+ */
+func registerType[T any]() {
+	if reflect.TypeFor[T]().Kind() == reflect.Interface {
+		return
+	}
+
+	var zero T
+	gob.Register(zero)
+}
+
+/**
+ * decode asserts a message payload to T
+ * @return a common.MicronetDecodeError if the payload is not a T
+ */
+func decode[T any](topic string, payload any) (T, error) {
+	msg, ok := payload.(T)
+	if !ok {
+		return msg, common.MicronetDecodeError{
+			Topic:    topic,
+			Expected: reflect.TypeFor[T]().String(),
+			Actual:   fmt.Sprintf("%T", payload),
+		}
+	}
+
+	return msg, nil
+}
+
+/**
+ * The TypedPublisher is a Publisher whose messages are of type T
+ */
+type TypedPublisher[T any] struct {
+	*Publisher
+}
+
+/**
+ * InitTypedPublisher creates a Publisher of T messages, registering T to gob
+ * @param network is the server's configuration
+ * @return the initialized TypedPublisher or error
+ */
+func InitTypedPublisher[T any](network common.NetConf) (*TypedPublisher[T], error) {
+	registerType[T]()
+
+	pub, err := InitPublisher(network)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedPublisher[T]{Publisher: pub}, nil
+}
+
+/**
+ * Publish a T message
+ * @param topic is the message's topic, it cannot contain wildcards
+ * @param msg is the message
+ * @return the Publisher's errors
+ */
+func (p *TypedPublisher[T]) Publish(topic string, msg T) error {
+	return p.Publisher.Publish(topic, msg)
+}
+
+/**
+ * The TypedSubscriber is a Subscriber recieving messages of type T
+ * A message of another type is acknowledged, then reported to the error handler as a common.MicronetDecodeError
+ */
+type TypedSubscriber[T any] struct {
+	*Subscriber
+	typedMutex   sync.Mutex
+	typedTopics  map[string]chan T
+	errorHandler func(error)
+}
+
+/**
+ * InitTypedSubscriber creates a Subscriber of T messages, registering T to gob
+ * @param selfNetwork is the server's network config
+ * @param remoteNetwork is the remote server's network config
+ * @return the initialized TypedSubscriber or error
+ */
+func InitTypedSubscriber[T any](selfNetwork common.NetConf, remoteNetwork common.NetConf) (*TypedSubscriber[T], error) {
+	registerType[T]()
+
+	sub, err := InitSubscriber(selfNetwork, remoteNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedSubscriber[T]{
+		Subscriber:  sub,
+		typedTopics: make(map[string]chan T),
+		errorHandler: func(err error) {
+			log.Println(err.Error())
+		},
+	}, nil
+}
+
+/**
+ * SetErrorHandler sets the function called with the decode errors, they are logged by default
+ * @param handler is called with every common.MicronetDecodeError
+ */
+func (s *TypedSubscriber[T]) SetErrorHandler(handler func(error)) {
+	s.typedMutex.Lock()
+	defer s.typedMutex.Unlock()
+
+	s.errorHandler = handler
+}
+
+func (s *TypedSubscriber[T]) reportError(err error) {
+	s.typedMutex.Lock()
+	handler := s.errorHandler
+	s.typedMutex.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+/**
+ * Topic channel getter, the channel is created on first use
+ * @param pattern is the topic pattern to recieve messages from, wildcards are allowed
+ */
+func (s *TypedSubscriber[T]) Topic(pattern string) chan T {
+	s.typedMutex.Lock()
+	defer s.typedMutex.Unlock()
+
+	topicChan, exist := s.typedTopics[pattern]
+	if exist {
+		return topicChan
+	}
+
+	topicChan = make(chan T)
+	s.typedTopics[pattern] = topicChan
+	s.handle(pattern, func(topic string, msg T) error {
+		select {
+		case topicChan <- msg:
+			return nil
+		case <-s.closing:
+			return errSubscriberClosed
+		}
+	})
+
+	return topicChan
+}
+
+/**
+ * Handle sets the callback called for every T message matching a pattern, instead of forwarding it to a channel
+ * @param pattern is the topic pattern to recieve messages from, wildcards are allowed
+ * @param callback is called with the message, a nil callback removes the previous one
+ */
+func (s *TypedSubscriber[T]) Handle(pattern string, callback func(T)) {
+	if callback == nil {
+		s.Subscriber.Handle(pattern, nil)
+		return
+	}
+
+	s.handle(pattern, func(topic string, msg T) error {
+		callback(msg)
+		return nil
+	})
+}
+
+func (s *TypedSubscriber[T]) handle(pattern string, callback func(string, T) error) {
+	s.Subscriber.handle(pattern, func(req *common.Message) error {
+		msg, err := decode[T](req.Topic, req.Payload)
+		if err != nil {
+			s.reportError(err)
+			return nil
+		}
+		return callback(req.Topic, msg)
+	})
+}
+
+/**
+ * Close the running server, then close every typed channel
+ * Same as Stop()
+ */
+func (s *TypedSubscriber[T]) Close() error {
+	err := s.Subscriber.Close()
+
+	s.typedMutex.Lock()
+	defer s.typedMutex.Unlock()
+
+	for _, topicChan := range s.typedTopics {
+		close(topicChan)
+	}
+	s.typedTopics = make(map[string]chan T)
+
+	return err
+}
+
+/**
+ * Stop the running server
+ * Same as Close()
+ */
+func (s *TypedSubscriber[T]) Stop() error {
+	return s.Close()
+}
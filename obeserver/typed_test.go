@@ -0,0 +1,117 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type OrderEvent struct {
+	ID   int
+	Item string
+}
+
+func startTypedPubSub(t *testing.T, pubPort string, subPort string) (*TypedPublisher[OrderEvent], *TypedSubscriber[OrderEvent]) {
+	pubConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: pubPort}
+	subConf := common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: subPort}
+
+	pub, errPub := InitTypedPublisher[OrderEvent](pubConf)
+	if !assert.NoError(t, errPub) {
+		t.FailNow()
+	}
+	go pub.Start()
+	time.Sleep(ListenReadynessDuration)
+
+	sub, errSub := InitTypedSubscriber[OrderEvent](subConf, pubConf)
+	if !assert.NoError(t, errSub) {
+		t.FailNow()
+	}
+	go sub.Start()
+	time.Sleep(ListenReadynessDuration)
+
+	t.Cleanup(func() {
+		pub.Stop()
+		sub.Stop()
+	})
+
+	return pub, sub
+}
+
+func TestTypedPubSub(t *testing.T) {
+	t.Run("Typed channel", func(t *testing.T) {
+		pub, sub := startTypedPubSub(t, "16024", "16025")
+
+		orders := sub.Topic("orders.>")
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders.>"))
+
+		assert.NoError(t, pub.Publish("orders.created", OrderEvent{ID: 1, Item: "book"}))
+
+		select {
+		case order := <-orders:
+			assert.Equal(t, OrderEvent{ID: 1, Item: "book"}, order)
+		case <-time.After(ReceiveTimeout):
+			t.Error("no message recieved")
+		}
+	})
+
+	t.Run("Mismatched payload", func(t *testing.T) {
+		pub, sub := startTypedPubSub(t, "16026", "16027")
+
+		errs := make(chan error, 1)
+		sub.SetErrorHandler(func(err error) { errs <- err })
+
+		recieved := make(chan OrderEvent, 1)
+		sub.Handle("orders", func(order OrderEvent) { recieved <- order })
+		assert.NoError(t, sub.Subscribe(pub.NetConf, "orders"))
+
+		assert.NoError(t, pub.Publisher.Publish("orders", "not an order"))
+
+		select {
+		case err := <-errs:
+			var errDecode common.MicronetDecodeError
+			assert.ErrorAs(t, err, &errDecode)
+			assert.Equal(t, "string", errDecode.Actual)
+			assert.Contains(t, errDecode.Expected, "OrderEvent")
+		case <-time.After(ReceiveTimeout):
+			t.Error("no decode error reported")
+		}
+		assert.Empty(t, recieved)
+
+		// the mismatched message is acknowledged, not redelivered
+		assert.Eventually(t, func() bool {
+			return pub.Stats()[sub.Server.NetConf].Delivered == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestTypedDecode(t *testing.T) {
+	order, err := decode[OrderEvent]("orders", OrderEvent{ID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, order.ID)
+
+	_, err = decode[OrderEvent]("orders", 42)
+	assert.EqualError(t, err, `message of topic "orders" is a int, expected a common.OrderEvent`)
+}
+
+func TestTypedClosing(t *testing.T) {
+	sub := &TypedSubscriber[OrderEvent]{Subscriber: newTestSubscriberHandler(), typedTopics: make(map[string]chan OrderEvent)}
+	sub.Topic("orders")
+
+	acked := make(chan uint64, 1)
+	sub.ack = func(msg *common.Message) { acked <- msg.Seq }
+
+	updated := make(chan error, 1)
+	go func() {
+		msg := &common.Message{Topic: "orders", Payload: OrderEvent{ID: 1}, Stream: 42, Seq: 1}
+		updated <- sub.Update(msg, &common.UpdateResponse{})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// the message is left to the publisher's redelivery
+	close(sub.closing)
+	assert.ErrorIs(t, <-updated, errSubscriberClosed)
+	assert.Empty(t, acked)
+}
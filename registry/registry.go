@@ -0,0 +1,207 @@
+package registry
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"micronet/common"
+	"micronet/server"
+)
+
+// The lease of an instance registered without a TTL
+const DefaultTTL = 10 * time.Second
+
+/**
+ * The basic Registry functions
+ */
+type I_Registry interface {
+	Register(*common.RegisterRequest, *common.RegisterResponse) error
+	Heartbeat(*common.HeartbeatRequest, *common.HeartbeatResponse) error
+	Deregister(*common.DeregisterRequest, *common.DeregisterResponse) error
+	Resolve(*common.ResolveRequest, *common.ResolveResponse) error
+}
+
+/**
+ * The RegistryServer is a Server discovering the instances of services by name with its Registry handler
+ */
+type RegistryServer struct {
+	*server.Server
+	Handler *Registry
+}
+
+/**
+ * The Registry handler holds the leases of the registered instances, indexed by service name
+ * An instance is listed until its lease expires or it deregisters, every heartbeat renews the lease
+ */
+type Registry struct {
+	I_Registry
+	mutex     sync.Mutex
+	instances map[string]map[common.NetConf]*lease
+}
+
+/**
+ * The lease is a registered instance and its expiry
+ */
+type lease struct {
+	instance  common.ServiceInstance
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+/**
+ * NewRegistry creates a Registry handler without instances
+ */
+func NewRegistry() *Registry {
+	return &Registry{instances: make(map[string]map[common.NetConf]*lease)}
+}
+
+/**
+ * NewRegistryServer creates a Server with a Registry handler, start it like any Server
+ * @param network is the registry's configuration
+ * @return the initialized RegistryServer or error
+ */
+func NewRegistryServer(network common.NetConf) (*RegistryServer, error) {
+	srv, err := server.NewServer(network)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &RegistryServer{Server: srv, Handler: NewRegistry()}
+
+	err = registry.Register(registry.Handler)
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+/**
+ * Register lists an instance under its NetConf.Name, or renews it with new metadata if already listed
+ * @param req.TTL is the requested lease, DefaultTTL when not positive
+ * @param res.TTL is the granted lease, the instance must heartbeat before it expires
+ */
+func (r *Registry) Register(req *common.RegisterRequest, res *common.RegisterResponse) error {
+	network := req.Instance.NetConf
+	if network.Name == "" {
+		return common.Errorf(common.InvalidArgument, "cannot register an instance without a name")
+	}
+
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for name := range r.instances {
+		r.expire(name, now)
+	}
+
+	leases, ok := r.instances[network.Name]
+	if !ok {
+		leases = make(map[common.NetConf]*lease)
+		r.instances[network.Name] = leases
+	}
+	if _, ok := leases[network]; !ok {
+		log.Printf("Registering instance %+v", network)
+	}
+	leases[network] = &lease{instance: req.Instance, ttl: ttl, expiresAt: now.Add(ttl)}
+
+	res.TTL = ttl
+
+	return nil
+}
+
+/**
+ * Heartbeat renews the lease of a registered instance
+ * @return a common.MicronetUnknownInstanceError if the instance is not listed, it must register again
+ */
+func (r *Registry) Heartbeat(req *common.HeartbeatRequest, res *common.HeartbeatResponse) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.expire(req.NetConf.Name, now)
+
+	lease, ok := r.instances[req.NetConf.Name][req.NetConf]
+	if !ok {
+		return common.MicronetUnknownInstanceError{NetConf: req.NetConf}
+	}
+	lease.expiresAt = now.Add(lease.ttl)
+
+	res.Ok = true
+
+	return nil
+}
+
+/**
+ * Deregister removes an instance before its lease expires
+ */
+func (r *Registry) Deregister(req *common.DeregisterRequest, res *common.DeregisterResponse) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	leases := r.instances[req.NetConf.Name]
+	if _, ok := leases[req.NetConf]; ok {
+		log.Printf("Deregistering instance %+v", req.NetConf)
+		delete(leases, req.NetConf)
+	}
+	if len(leases) == 0 {
+		delete(r.instances, req.NetConf.Name)
+	}
+
+	res.Ok = true
+
+	return nil
+}
+
+/**
+ * Resolve lists the live instances of a service, possibly none
+ */
+func (r *Registry) Resolve(req *common.ResolveRequest, res *common.ResolveResponse) error {
+	res.Instances = r.Instances(req.Name)
+
+	return nil
+}
+
+/**
+ * Instances lists the live instances of a service, ordered by address
+ */
+func (r *Registry) Instances(name string) []common.ServiceInstance {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.expire(name, time.Now())
+
+	instances := make([]common.ServiceInstance, 0, len(r.instances[name]))
+	for _, lease := range r.instances[name] {
+		instances = append(instances, lease.instance)
+	}
+	slices.SortFunc(instances, func(a, b common.ServiceInstance) int {
+		return cmp.Or(cmp.Compare(a.NetConf.Ip, b.NetConf.Ip), cmp.Compare(a.NetConf.Port, b.NetConf.Port))
+	})
+
+	return instances
+}
+
+/**
+ * expire removes the expired leases of a service, the mutex must be held
+ */
+func (r *Registry) expire(name string, now time.Time) {
+	leases := r.instances[name]
+	for network, lease := range leases {
+		if now.After(lease.expiresAt) {
+			log.Printf("Lease of instance %+v expired", network)
+			delete(leases, network)
+		}
+	}
+	if len(leases) == 0 {
+		delete(r.instances, name)
+	}
+}
@@ -0,0 +1,319 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"micronet/client"
+	"micronet/clientServer"
+	"micronet/common"
+	pubsub "micronet/obeserver"
+	"micronet/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ListenReadynessDuration time.Duration = time.Millisecond * 100
+
+type BillingService struct {
+	port string
+}
+
+func (s *BillingService) Name(req string, res *string) error {
+	*res = s.port
+	return nil
+}
+
+func netConf(name string, port string) common.NetConf {
+	return common.NetConf{Name: name, Protocol: "tcp", Ip: "127.0.0.1", Port: port}
+}
+
+func startRegistry(t *testing.T, port string) *RegistryServer {
+	registry, err := NewRegistryServer(netConf("registry", port))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	go registry.Start()
+	t.Cleanup(registry.Stop)
+	time.Sleep(ListenReadynessDuration)
+
+	return registry
+}
+
+/**
+ * startInstance serves a BillingService and registers it
+ * @return the Registration to stop to deregister the instance
+ */
+func startInstance(t *testing.T, registryPort string, port string) *client.Registration {
+	srv, err := server.NewServer(netConf("billing", port))
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Register(&BillingService{port: port}))
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+	time.Sleep(ListenReadynessDuration)
+
+	instance := common.ServiceInstance{NetConf: netConf("billing", port), Metadata: map[string]string{"port": port}}
+	registration := client.NewRegistration(netConf("registry", registryPort), instance, 200*time.Millisecond)
+	if !assert.NoError(t, registration.Start()) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { registration.Stop() })
+
+	return registration
+}
+
+func ports(instances []common.ServiceInstance) []string {
+	var ports []string
+	for _, instance := range instances {
+		ports = append(ports, instance.NetConf.Port)
+	}
+
+	return ports
+}
+
+func TestRegistry_Leases(t *testing.T) {
+	registry := NewRegistry()
+
+	register := func(port string, ttl time.Duration) common.RegisterResponse {
+		res := common.RegisterResponse{}
+		req := &common.RegisterRequest{Instance: common.ServiceInstance{NetConf: netConf("billing", port)}, TTL: ttl}
+		assert.NoError(t, registry.Register(req, &res))
+		return res
+	}
+
+	assert.Equal(t, DefaultTTL, register("2", 0).TTL)
+	assert.Equal(t, 50*time.Millisecond, register("1", 50*time.Millisecond).TTL)
+	assert.Equal(t, []string{"1", "2"}, ports(registry.Instances("billing")))
+	assert.Empty(t, registry.Instances("shipping"))
+
+	// a heartbeat renews the lease
+	time.Sleep(30 * time.Millisecond)
+	assert.NoError(t, registry.Heartbeat(&common.HeartbeatRequest{NetConf: netConf("billing", "1")}, &common.HeartbeatResponse{}))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, []string{"1", "2"}, ports(registry.Instances("billing")))
+
+	// an expired lease must be registered again
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, []string{"2"}, ports(registry.Instances("billing")))
+	err := registry.Heartbeat(&common.HeartbeatRequest{NetConf: netConf("billing", "1")}, &common.HeartbeatResponse{})
+	assert.ErrorIs(t, err, common.MicronetUnknownInstanceError{NetConf: netConf("billing", "1")})
+	assert.Equal(t, common.NotFound, common.CodeOf(err))
+
+	assert.NoError(t, registry.Deregister(&common.DeregisterRequest{NetConf: netConf("billing", "2")}, &common.DeregisterResponse{}))
+	assert.Empty(t, registry.Instances("billing"))
+
+	err = registry.Register(&common.RegisterRequest{Instance: common.ServiceInstance{NetConf: netConf("", "3")}}, &common.RegisterResponse{})
+	assert.Equal(t, common.InvalidArgument, common.CodeOf(err))
+}
+
+func TestRegistry_Discovery(t *testing.T) {
+	registry := startRegistry(t, "17200")
+
+	first := startInstance(t, "17200", "17201")
+	startInstance(t, "17200", "17202")
+
+	resolver := client.NewRegistryResolver(netConf("registry", "17200"))
+	defer resolver.Close()
+
+	conf := client.DefaultBalancerConf
+	conf.HealthInterval = 0
+	conf.ResolveInterval = 20 * time.Millisecond
+	cli, err := client.NewResolvedClient(resolver, "billing", conf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cli.Close()
+
+	names := func() []string {
+		var names []string
+		for range 6 {
+			var name string
+			assert.NoError(t, cli.Call("BillingService.Name", "", &name))
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	t.Run("Instances are discovered by name", func(t *testing.T) {
+		assert.Equal(t, []string{"17201", "17202"}, names())
+		assert.Equal(t, map[string]string{"port": "17201"}, registry.Handler.Instances("billing")[0].Metadata)
+	})
+
+	t.Run("Heartbeats keep the leases", func(t *testing.T) {
+		time.Sleep(500 * time.Millisecond)
+		assert.Equal(t, []string{"17201", "17202"}, ports(registry.Handler.Instances("billing")))
+	})
+
+	parent := t
+	t.Run("New instances are followed", func(t *testing.T) {
+		startInstance(parent, "17200", "17203")
+		assert.Eventually(t, func() bool {
+			return len(cli.Healthy()) == 3
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"17201", "17202", "17203"}, names())
+	})
+
+	t.Run("Deregistered instances are dropped", func(t *testing.T) {
+		assert.NoError(t, first.Stop())
+		assert.Eventually(t, func() bool {
+			return len(cli.Healthy()) == 2
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"17202", "17203"}, names())
+	})
+
+	t.Run("Lost leases are registered again", func(t *testing.T) {
+		registry.Handler.Deregister(&common.DeregisterRequest{NetConf: netConf("billing", "17202")}, &common.DeregisterResponse{})
+		assert.Equal(t, []string{"17203"}, ports(registry.Handler.Instances("billing")))
+		assert.Eventually(t, func() bool {
+			return len(registry.Handler.Instances("billing")) == 2
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("NewClientByName uses the default resolver", func(t *testing.T) {
+		_, err := client.NewClientByName("billing")
+		assert.Error(t, err)
+
+		client.SetDefaultResolver(resolver)
+		defer client.SetDefaultResolver(nil)
+
+		byName, err := client.NewClientByName("billing")
+		if assert.NoError(t, err) {
+			var name string
+			assert.NoError(t, byName.Call("BillingService.Name", "", &name))
+			byName.Close()
+		}
+
+		_, err = client.NewClientByName("shipping")
+		assert.ErrorIs(t, err, common.MicronetUnknownServiceError{Name: "shipping"})
+	})
+}
+
+func TestRegistry_SelfRegistration(t *testing.T) {
+	registry := startRegistry(t, "17210")
+	registration := server.RegistrationConf{
+		Registry: netConf("registry", "17210"),
+		Metadata: map[string]string{"zone": "eu"},
+		TTL:      200 * time.Millisecond,
+	}
+
+	t.Run("Server registers its bound address until stopped", func(t *testing.T) {
+		srv, err := server.NewServer(netConf("billing", "0"))
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Register(&BillingService{port: "0"}))
+		srv.SetRegistration(registration)
+		go srv.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		instances := registry.Handler.Instances("billing")
+		if !assert.Len(t, instances, 1) {
+			t.FailNow()
+		}
+		assert.Equal(t, strconv.Itoa(srv.Addr().(*net.TCPAddr).Port), instances[0].NetConf.Port)
+		assert.Equal(t, "127.0.0.1", instances[0].NetConf.Ip)
+		assert.Equal(t, map[string]string{"zone": "eu"}, instances[0].Metadata)
+
+		// the lease is renewed in the background
+		time.Sleep(500 * time.Millisecond)
+		assert.Len(t, registry.Handler.Instances("billing"), 1)
+
+		cli, err := client.NewResolvedClient(client.NewRegistryResolver(netConf("registry", "17210")), "billing", client.DefaultBalancerConf)
+		if assert.NoError(t, err) {
+			var name string
+			assert.NoError(t, cli.Call("BillingService.Name", "", &name))
+			cli.Close()
+		}
+
+		srv.Stop()
+		assert.Empty(t, registry.Handler.Instances("billing"))
+	})
+
+	t.Run("Shutdown deregisters before draining", func(t *testing.T) {
+		srv, err := server.NewServer(netConf("billing", "17211"))
+		assert.NoError(t, err)
+		srv.SetRegistration(registration)
+		go srv.Start()
+		time.Sleep(ListenReadynessDuration)
+		assert.Len(t, registry.Handler.Instances("billing"), 1)
+
+		assert.NoError(t, srv.Shutdown(context.Background()))
+		assert.Empty(t, registry.Handler.Instances("billing"))
+	})
+
+	t.Run("Server registers once the registry is reachable", func(t *testing.T) {
+		srv, err := server.NewServer(netConf("billing", "17212"))
+		assert.NoError(t, err)
+		srv.SetRegistration(server.RegistrationConf{
+			Registry:  netConf("registry", "17219"),
+			TTL:       200 * time.Millisecond,
+			Configure: func(cli *client.Client) { cli.SetReconnectionConf(1, 0) },
+		})
+		started := make(chan error, 1)
+		go func() { started <- srv.Start() }()
+		time.Sleep(ListenReadynessDuration)
+
+		// the unreachable registry neither fails nor delays Start
+		cli, err := client.NewClient(netConf("billing", "17212"))
+		if assert.NoError(t, err) {
+			assert.NoError(t, cli.Ping())
+			cli.Close()
+		}
+		assert.Empty(t, started)
+
+		late := startRegistry(t, "17219")
+		assert.Eventually(t, func() bool { return len(late.Handler.Instances("billing")) == 1 }, 2*time.Second, 10*time.Millisecond)
+
+		srv.Stop()
+		assert.Empty(t, late.Handler.Instances("billing"))
+		assert.NoError(t, <-started)
+	})
+
+	t.Run("Stop gives an unreachable registry a bounded time", func(t *testing.T) {
+		srv, err := server.NewServer(netConf("billing", "17216"))
+		assert.NoError(t, err)
+		srv.SetRegistration(server.RegistrationConf{Registry: netConf("registry", "17218")})
+		go srv.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		start := time.Now()
+		srv.Stop()
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("ClientServer, Publisher and Subscriber inherit the registration", func(t *testing.T) {
+		cs, err := clientServer.NewClientServer(netConf("worker", "17213"), netConf("registry", "17210"))
+		assert.NoError(t, err)
+		cs.SetRegistration(registration)
+		go cs.Start()
+
+		pubConf := netConf("publisher", "17214")
+		pub, err := pubsub.InitPublisher(pubConf)
+		assert.NoError(t, err)
+		pub.SetRegistration(registration)
+		go pub.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		sub, err := pubsub.InitSubscriber(netConf("subscriber", "17215"), pubConf)
+		assert.NoError(t, err)
+		sub.SetRegistration(registration)
+		go sub.Start()
+		time.Sleep(ListenReadynessDuration)
+
+		for _, name := range []string{"worker", "publisher", "subscriber"} {
+			assert.Len(t, registry.Handler.Instances(name), 1, name)
+		}
+
+		cs.Stop()
+		pub.Stop()
+		sub.Stop()
+		for _, name := range []string{"worker", "publisher", "subscriber"} {
+			assert.Empty(t, registry.Handler.Instances(name), name)
+		}
+	})
+}
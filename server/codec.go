@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/rpc"
+
+	"micronet/common"
+)
+
+/**
+ * The trackedCodec counts the server's in-flight calls and refuses new ones once the server is shutting down
+ * Every request header successfully read is answered by exactly one WriteResponse
+ */
+type trackedCodec struct {
+	rpc.ServerCodec
+	server  *Server
+	refused bool
+}
+
+func (c *trackedCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err != nil {
+		return err
+	}
+
+	c.server.inFlight.Add(1)
+	c.refused = c.server.ctx.Err() != nil
+
+	return nil
+}
+
+func (c *trackedCodec) ReadRequestBody(body any) error {
+	if !c.refused {
+		return c.ServerCodec.ReadRequestBody(body)
+	}
+
+	// the body must still be consumed to keep the stream in sync
+	c.ServerCodec.ReadRequestBody(nil)
+
+	return common.MicronetShutdownError{NetConf: c.server.NetConf}
+}
+
+func (c *trackedCodec) WriteResponse(r *rpc.Response, body any) error {
+	defer c.server.inFlight.Add(-1)
+
+	return c.ServerCodec.WriteResponse(r, body)
+}
@@ -0,0 +1,330 @@
+package server
+
+import (
+	"errors"
+	"go/token"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"reflect"
+	"strings"
+	"sync"
+
+	"micronet/codec"
+	"micronet/common"
+)
+
+// The body of an error response, as sent by net/rpc
+var invalidRequest = struct{}{}
+
+var typeOfError = reflect.TypeFor[error]()
+
+/**
+ * The service is a registered receiver and its handler methods
+ */
+type service struct {
+	rcvr    reflect.Value
+	methods map[string]*methodType
+}
+
+/**
+ * The methodType is a handler method, following the net/rpc prototype func (T) Method(args, *reply) error
+ */
+type methodType struct {
+	method    reflect.Method
+	argType   reflect.Type
+	replyType reflect.Type
+}
+
+/**
+ * newService collects the receiver's handler methods, the receiver must already be accepted by rpc.Server.Register
+ */
+func newService(rcvr any) (string, *service) {
+	svc := &service{rcvr: reflect.ValueOf(rcvr), methods: make(map[string]*methodType)}
+	rcvrType := reflect.TypeOf(rcvr)
+
+	for i := 0; i < rcvrType.NumMethod(); i++ {
+		method := rcvrType.Method(i)
+		mtype := method.Type
+		if !method.IsExported() || mtype.NumIn() != 3 || mtype.NumOut() != 1 {
+			continue
+		}
+
+		argType, replyType := mtype.In(1), mtype.In(2)
+		if replyType.Kind() != reflect.Pointer || !isExportedOrBuiltin(argType) || !isExportedOrBuiltin(replyType) {
+			continue
+		}
+		if mtype.Out(0) != typeOfError {
+			continue
+		}
+
+		svc.methods[method.Name] = &methodType{method: method, argType: argType, replyType: replyType}
+	}
+
+	return reflect.Indirect(svc.rcvr).Type().Name(), svc
+}
+
+func isExportedOrBuiltin(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return token.IsExported(t.Name()) || t.PkgPath() == ""
+}
+
+/**
+ * newArgs allocates the handler's arguments, and the pointer they are decoded into
+ */
+func (m *methodType) newArgs() (argv reflect.Value, decoded any) {
+	if m.argType.Kind() == reflect.Pointer {
+		argv = reflect.New(m.argType.Elem())
+		return argv, argv.Interface()
+	}
+
+	ptr := reflect.New(m.argType)
+	return ptr.Elem(), ptr.Interface()
+}
+
+func (m *methodType) newReply() reflect.Value {
+	replyv := reflect.New(m.replyType.Elem())
+
+	switch m.replyType.Elem().Kind() {
+	case reflect.Map:
+		replyv.Elem().Set(reflect.MakeMap(m.replyType.Elem()))
+	case reflect.Slice:
+		replyv.Elem().Set(reflect.MakeSlice(m.replyType.Elem(), 0, 0))
+	}
+
+	return replyv
+}
+
+func (m *methodType) invoke(rcvr reflect.Value, argv reflect.Value, replyv reflect.Value) error {
+	returned := m.method.Func.Call([]reflect.Value{rcvr, argv, replyv})
+	if err := returned[0].Interface(); err != nil {
+		return err.(error)
+	}
+
+	return nil
+}
+
+/**
+ * lookup finds the service and method of a "Service.Method" name
+ */
+func (s *Server) lookup(serviceMethod string) (*service, *methodType, error) {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return nil, nil, errors.New("rpc: service/method request ill-formed: " + serviceMethod)
+	}
+
+	svci, found := s.services.Load(serviceMethod[:dot])
+	if !found {
+		return nil, nil, errors.New("rpc: can't find service " + serviceMethod)
+	}
+
+	svc := svci.(*service)
+	mtype, found := svc.methods[serviceMethod[dot+1:]]
+	if !found {
+		return nil, nil, errors.New("rpc: can't find method " + serviceMethod)
+	}
+
+	return svc, mtype, nil
+}
+
+/**
+ * serveCodec reads the requests of a connexion and runs each one through the interceptors in its own goroutine,
+ * until the connexion is closed
+ * @param codec is the connexion's codec
+ * @param peer is the connexion's remote
+ */
+func (s *Server) serveCodec(codec rpc.ServerCodec, peer common.PeerInfo) {
+	sending := new(sync.Mutex)
+	calls := new(sync.WaitGroup)
+
+	for {
+		call, errHeader := s.readRequest(codec, sending, peer)
+		if errHeader != nil {
+			if errHeader != io.EOF && !errors.Is(errHeader, io.ErrUnexpectedEOF) && s.ctx.Err() == nil {
+				log.Printf("Error reading request header from %s: %s", peer.PeerAddr, errHeader)
+			}
+			break
+		}
+		if call == nil {
+			continue
+		}
+
+		calls.Add(1)
+		go func() {
+			defer calls.Done()
+			call()
+		}()
+	}
+
+	calls.Wait()
+	codec.Close()
+}
+
+/**
+ * readRequest reads the next request of a connexion, answering at once the ones that cannot be dispatched
+ * @param codec is the connexion's codec
+ * @param sending serializes the responses of the connexion
+ * @param peer is the connexion's remote
+ * @return the call running the request through the interceptors and answering it, nil if it was already answered,
+ * or the error reading the request header
+ */
+func (s *Server) readRequest(codec rpc.ServerCodec, sending *sync.Mutex, peer common.PeerInfo) (func(), error) {
+	var req rpc.Request
+	errHeader := codec.ReadRequestHeader(&req)
+	if errHeader != nil {
+		return nil, errHeader
+	}
+
+	svc, mtype, errLookup := s.lookup(req.ServiceMethod)
+	if errLookup != nil {
+		codec.ReadRequestBody(nil)
+		sendResponse(codec, sending, &req, invalidRequest, errLookup)
+		return nil, nil
+	}
+
+	argv, decoded := mtype.newArgs()
+	errBody := codec.ReadRequestBody(decoded)
+	if errBody != nil {
+		sendResponse(codec, sending, &req, invalidRequest, errBody)
+		return nil, nil
+	}
+	if setter, ok := decoded.(common.PeerSetter); ok {
+		setter.SetPeer(peer)
+	}
+
+	replyv := mtype.newReply()
+	info := &CallInfo{
+		ServiceMethod: req.ServiceMethod,
+		Args:          argv.Interface(),
+		Reply:         replyv.Interface(),
+		Peer:          peer,
+	}
+
+	return func() {
+		// a panic is recovered around the handler, for the interceptors to see it as an error,
+		// and around the chain, for the panics of the interceptors themselves
+		errCall := s.recoverCall(info.ServiceMethod, func() error {
+			return s.intercept(info, func() error {
+				return s.recoverCall(info.ServiceMethod, func() error {
+					return mtype.invoke(svc.rcvr, argv, replyv)
+				})
+			})
+		})
+		s.counters.calls.Add(1)
+		if errCall != nil {
+			s.counters.failed.Add(1)
+			sendResponse(codec, sending, &req, invalidRequest, errCall)
+			return
+		}
+		sendResponse(codec, sending, &req, replyv.Interface(), nil)
+	}, nil
+}
+
+/**
+ * ServeConn serves a connexion with the Server's codec, without handshake, until the client hangs up
+ * @param conn is the connexion, usually a net.Conn
+ */
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	serverCodec, errCodec := codec.Lookup(s.Codec)
+	if errCodec != nil {
+		log.Printf("Error serving connection: %s", errCodec)
+		conn.Close()
+		return
+	}
+
+	s.ServeCodec(serverCodec.NewServerCodec(conn))
+}
+
+/**
+ * ServeCodec serves the requests of a codec until the client hangs up
+ * @param codec is the connexion's codec
+ */
+func (s *Server) ServeCodec(codec rpc.ServerCodec) {
+	s.serveCodec(&trackedCodec{ServerCodec: codec, server: s}, common.PeerInfo{})
+}
+
+/**
+ * ServeRequest serves a single request of a codec, synchronously
+ * @param codec is the connexion's codec
+ * @return the error reading the request header
+ */
+func (s *Server) ServeRequest(codec rpc.ServerCodec) error {
+	call, errHeader := s.readRequest(&trackedCodec{ServerCodec: codec, server: s}, new(sync.Mutex), common.PeerInfo{})
+	if errHeader != nil {
+		return errHeader
+	}
+	if call != nil {
+		call()
+	}
+
+	return nil
+}
+
+/**
+ * Accept serves the connexions of a listener until it is closed, as Start does with its own
+ * @param listener is the listener to accept connexions from
+ */
+func (s *Server) Accept(listener net.Listener) {
+	for {
+		conn, errAccept := listener.Accept()
+		if errAccept != nil {
+			log.Printf("Error accepting connection: %s", errAccept)
+			return
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
+		}
+		go s.serveConn(conn)
+	}
+}
+
+/**
+ * ServeHTTP answers an HTTP CONNECT with the rpc protocol, as rpc.Server does
+ */
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodConnect {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+
+	conn, _, errHijack := w.(http.Hijacker).Hijack()
+	if errHijack != nil {
+		log.Printf("rpc hijacking %s: %s", req.RemoteAddr, errHijack)
+		return
+	}
+	io.WriteString(conn, "HTTP/1.0 200 Connected to Go RPC\n\n")
+	s.ServeConn(conn)
+}
+
+/**
+ * HandleHTTP registers the Server on rpcPath of http.DefaultServeMux, the debugging page of rpc.Server is not served
+ * @param rpcPath is the path of the rpc endpoint, usually rpc.DefaultRPCPath
+ * @param debugPath is ignored
+ */
+func (s *Server) HandleHTTP(rpcPath string, debugPath string) {
+	http.Handle(rpcPath, s)
+}
+
+func sendResponse(codec rpc.ServerCodec, sending *sync.Mutex, req *rpc.Request, reply any, err error) {
+	resp := &rpc.Response{ServiceMethod: req.ServiceMethod, Seq: req.Seq}
+	if err != nil {
+		resp.Error = common.EncodeError(err)
+	}
+
+	sending.Lock()
+	defer sending.Unlock()
+
+	errWrite := codec.WriteResponse(resp, reply)
+	if errWrite != nil {
+		log.Printf("Error writing response of %s: %s", req.ServiceMethod, errWrite)
+	}
+}
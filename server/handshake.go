@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+
+	"micronet/codec"
+)
+
+// The time given to a client to send its preamble and read the answer
+const codecHandshakeTimeout = 10 * time.Second
+
+/**
+ * negotiate answers the preamble of a client opening the connexion with a handshake, and compresses it if requested
+ * Connexions without preamble, from older or foreign clients, are served with the Server's codec
+ * @param conn is the accepted connexion
+ * @return the connexion to read from and its codec, or the reason the handshake was refused
+ */
+func (s *Server) negotiate(conn net.Conn) (net.Conn, codec.Codec, error) {
+	reader := bufio.NewReader(conn)
+	buffered := &codec.BufferedConn{Conn: conn, Reader: reader}
+
+	if !codec.IsPreamble(reader) {
+		return buffered, s.codec, nil
+	}
+
+	conn.SetDeadline(time.Now().Add(codecHandshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	preamble, errRead := codec.ReadPreamble(reader)
+	if errRead != nil {
+		return nil, nil, errRead
+	}
+
+	answer, chosen := s.answer(preamble)
+	errWrite := codec.WriteAnswer(conn, answer)
+	if errWrite != nil {
+		return nil, nil, errWrite
+	}
+	if !answer.Accepted {
+		return nil, nil, fmt.Errorf("handshake refused: %s", answer.Reason)
+	}
+
+	if answer.Compression != "" {
+		compressor, _ := codec.LookupCompressor(answer.Compression)
+		return codec.NewCompressedConn(conn, reader, compressor), chosen, nil
+	}
+
+	return buffered, chosen, nil
+}
+
+/**
+ * answer decides the codec of a connexion from the client's preamble
+ */
+func (s *Server) answer(preamble codec.Preamble) (codec.Answer, codec.Codec) {
+	answer := codec.Answer{Version: preamble.Version}
+	if !codec.SupportsVersion(preamble.Version) {
+		answer.Version = codec.MaxVersion
+		answer.Reason = fmt.Sprintf("unsupported protocol version %d, server supports %d to %d", preamble.Version, codec.MinVersion, codec.MaxVersion)
+		return answer, nil
+	}
+
+	if preamble.Compression != "" {
+		if _, errCompression := codec.LookupCompressor(preamble.Compression); errCompression != nil {
+			answer.Reason = errCompression.Error()
+			return answer, nil
+		}
+		answer.Compression = preamble.Compression
+	}
+
+	answer.Codec = preamble.Codec
+	if answer.Codec == "" {
+		answer.Codec = s.Codec
+	}
+	if answer.Codec == "" {
+		answer.Codec = codec.Gob
+	}
+
+	chosen, errCodec := codec.Lookup(answer.Codec)
+	if errCodec != nil {
+		answer.Reason = errCodec.Error()
+		return answer, nil
+	}
+
+	answer.Accepted = true
+	return answer, chosen
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"micronet/codec"
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func startCodecServer(t *testing.T, port string, codecName string) {
+	srv, err := NewServer(common.NetConf{Protocol: "tcp", Ip: "127.0.0.1", Port: port, Codec: codecName})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Register(new(EchoService)))
+
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+	time.Sleep(ListenReadynessDuration)
+}
+
+/**
+ * handshake opens a connexion and sends a preamble
+ */
+func handshake(t *testing.T, port string, preamble codec.Preamble) (net.Conn, codec.Answer) {
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	assert.NoError(t, codec.WritePreamble(conn, preamble))
+	answer, err := codec.ReadAnswer(conn)
+	assert.NoError(t, err)
+
+	return conn, answer
+}
+
+func TestServerHandshake(t *testing.T) {
+	t.Run("Requested codec is served", func(t *testing.T) {
+		startCodecServer(t, "13018", codec.Gob)
+
+		conn, answer := handshake(t, "13018", codec.Preamble{Version: codec.MaxVersion, Codec: codec.MessagePack})
+		assert.True(t, answer.Accepted)
+		assert.Equal(t, codec.MessagePack, answer.Codec)
+
+		msgpack, _ := codec.Lookup(codec.MessagePack)
+		cli := rpc.NewClientWithCodec(msgpack.NewClientCodec(conn))
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "negotiated", &reply))
+		assert.Equal(t, "negotiated", reply)
+	})
+
+	t.Run("Server chooses its codec", func(t *testing.T) {
+		startCodecServer(t, "13019", codec.JSONRPC)
+
+		_, answer := handshake(t, "13019", codec.Preamble{Version: codec.MaxVersion})
+		assert.True(t, answer.Accepted)
+		assert.Equal(t, codec.JSONRPC, answer.Codec)
+	})
+
+	t.Run("Incompatible version is refused", func(t *testing.T) {
+		startCodecServer(t, "13020", "")
+
+		_, answer := handshake(t, "13020", codec.Preamble{Version: codec.MaxVersion + 1})
+		assert.False(t, answer.Accepted)
+		assert.Equal(t, codec.MaxVersion, answer.Version)
+		assert.Contains(t, answer.Reason, "unsupported protocol version")
+	})
+
+	t.Run("Unknown codec is refused", func(t *testing.T) {
+		startCodecServer(t, "13021", "")
+
+		_, answer := handshake(t, "13021", codec.Preamble{Version: codec.MaxVersion, Codec: "xml"})
+		assert.False(t, answer.Accepted)
+		assert.Equal(t, `unknown codec "xml"`, answer.Reason)
+	})
+
+	t.Run("Connexion without handshake uses the server's codec", func(t *testing.T) {
+		startCodecServer(t, "13022", codec.JSONRPC)
+
+		conn, err := net.Dial("tcp", "127.0.0.1:13022")
+		assert.NoError(t, err)
+		jsonrpc, _ := codec.Lookup(codec.JSONRPC)
+		cli := rpc.NewClientWithCodec(jsonrpc.NewClientCodec(conn))
+		defer cli.Close()
+
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "legacy", &reply))
+		assert.Equal(t, "legacy", reply)
+	})
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"micronet/common"
+)
+
+const (
+	// The wait of a Watch without Timeout
+	defaultWatchTimeout = 30 * time.Second
+	// The longest wait of a Watch
+	maxWatchTimeout = 5 * time.Minute
+)
+
+/**
+ * The basic Health functions
+ */
+type I_Health interface {
+	Check(*common.HealthCheckRequest, *common.HealthCheckResponse) error
+	Watch(*common.HealthWatchRequest, *common.HealthWatchResponse) error
+}
+
+/**
+ * The Health handler reports the status of the Server, under the empty service name, and of its registered services
+ * Every Server registers one under the name "Health", the application sets the statuses with Server.SetServingStatus
+ * Watch is a long poll: it answers once the status changed from the caller's version, so that a client
+ * watching a service calls it again with the version of the last answer
+ * Only Register and SetServingStatus add services, the unknown ones being watched through the created channel
+ */
+type Health struct {
+	I_Health
+	mutex    sync.Mutex
+	services map[string]*healthEntry
+	created  chan struct{}
+	closing  chan struct{}
+	closed   bool
+}
+
+/**
+ * The healthEntry is the status of a service, its changed channel is closed and replaced on every change
+ */
+type healthEntry struct {
+	status  common.HealthStatus
+	version uint64
+	changed chan struct{}
+}
+
+/**
+ * newHealth creates a Health handler with a serving Server
+ */
+func newHealth() *Health {
+	health := &Health{services: make(map[string]*healthEntry), created: make(chan struct{}), closing: make(chan struct{})}
+	health.set("", common.HealthServing)
+
+	return health
+}
+
+/**
+ * Check answers the status of a service, UNKNOWN if it is neither registered nor set
+ */
+func (h *Health) Check(req *common.HealthCheckRequest, res *common.HealthCheckResponse) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if entry, ok := h.services[req.Service]; ok {
+		res.Status, res.Version = entry.status, entry.version
+	}
+
+	return nil
+}
+
+/**
+ * Watch waits until the status of a service is no longer at req.Version, or until req.Timeout
+ * It answers at once when the version already changed or the Server is stopping
+ * A service that is neither registered nor set is UNKNOWN at version 0, until it is set
+ * @param req.Timeout bounds the wait, 30 seconds when 0 and at most 5 minutes
+ * @param res is the current status and version, unchanged if the wait timed out
+ */
+func (h *Health) Watch(req *common.HealthWatchRequest, res *common.HealthWatchResponse) error {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultWatchTimeout
+	}
+	timer := time.NewTimer(min(timeout, maxWatchTimeout))
+	defer timer.Stop()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for !h.closed {
+		entry, ok := h.services[req.Service]
+		changed := h.created
+		if ok && entry.version == req.Version {
+			changed = entry.changed
+		} else if ok || req.Version != 0 {
+			break
+		}
+
+		h.mutex.Unlock()
+		timedOut := false
+		select {
+		case <-changed:
+		case <-h.closing:
+		case <-timer.C:
+			timedOut = true
+		}
+		h.mutex.Lock()
+
+		if timedOut {
+			break
+		}
+	}
+
+	res.Status, res.Version = common.HealthUnknown, 0
+	if entry, ok := h.services[req.Service]; ok {
+		res.Status, res.Version = entry.status, entry.version
+	}
+
+	return nil
+}
+
+/**
+ * entry is the status of a service, created UNKNOWN at version 0 by Register and SetServingStatus only,
+ * the mutex must be held
+ */
+func (h *Health) entry(service string) *healthEntry {
+	entry, ok := h.services[service]
+	if !ok {
+		entry = &healthEntry{status: common.HealthUnknown, changed: make(chan struct{})}
+		h.services[service] = entry
+		close(h.created)
+		h.created = make(chan struct{})
+	}
+
+	return entry
+}
+
+/**
+ * set changes the status of a service and wakes its watchers, the statuses are frozen once shut down
+ * @return true if the status changed
+ */
+func (h *Health) set(service string, status common.HealthStatus) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return false
+	}
+
+	return h.update(service, status)
+}
+
+/**
+ * setDefault sets the status of a service that was never set
+ */
+func (h *Health) setDefault(service string, status common.HealthStatus) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if entry, ok := h.services[service]; !h.closed && (!ok || entry.version == 0) {
+		h.update(service, status)
+	}
+}
+
+/**
+ * update changes the status of a service and wakes its watchers, the mutex must be held
+ */
+func (h *Health) update(service string, status common.HealthStatus) bool {
+	entry := h.entry(service)
+	if entry.status == status && entry.version > 0 {
+		return false
+	}
+
+	entry.status = status
+	entry.version++
+	close(entry.changed)
+	entry.changed = make(chan struct{})
+
+	return true
+}
+
+/**
+ * get is the status of a service
+ */
+func (h *Health) get(service string) common.HealthStatus {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if entry, ok := h.services[service]; ok {
+		return entry.status
+	}
+
+	return common.HealthUnknown
+}
+
+/**
+ * shutdown sets every service NOT_SERVING and answers the running watches
+ */
+func (h *Health) shutdown() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.closed {
+		return
+	}
+	for service := range h.services {
+		h.update(service, common.HealthNotServing)
+	}
+	h.closed = true
+	close(h.closing)
+}
+
+/**
+ * SetServingStatus sets the status reported by the Health service, and notifies its watchers
+ * The empty service is the whole Server, which is SERVING once created, the registered services are SERVING too
+ * The statuses are NOT_SERVING once the Server is stopped or shut down, and can no longer be set
+ * Use it to report a Server that is up but cannot serve, because its database is down for instance
+ * @param service is a registered service's name, like "PingHandler", or "" for the Server
+ * @param status is the new status
+ */
+func (s *Server) SetServingStatus(service string, status common.HealthStatus) {
+	if s.health.set(service, status) {
+		log.Printf("Service %q of server %+v is %s", service, s.NetConf, status)
+	}
+}
+
+/**
+ * ServingStatus is the status reported by the Health service
+ * @param service is a registered service's name, or "" for the Server
+ */
+func (s *Server) ServingStatus(service string) common.HealthStatus {
+	return s.health.get(service)
+}
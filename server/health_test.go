@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func check(t *testing.T, cli *rpc.Client, service string) common.HealthCheckResponse {
+	res := common.HealthCheckResponse{}
+	assert.NoError(t, cli.Call("Health.Check", &common.HealthCheckRequest{Service: service}, &res))
+	return res
+}
+
+func watch(cli *rpc.Client, service string, version uint64, timeout time.Duration) *rpc.Call {
+	req := &common.HealthWatchRequest{Service: service, Version: version, Timeout: timeout}
+	return cli.Go("Health.Watch", req, &common.HealthWatchResponse{}, nil)
+}
+
+func watched(t *testing.T, call *rpc.Call) common.HealthWatchResponse {
+	select {
+	case <-call.Done:
+		assert.NoError(t, call.Error)
+		return *call.Reply.(*common.HealthWatchResponse)
+	case <-time.After(time.Second):
+		t.Error("watch not answered")
+		return common.HealthWatchResponse{}
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	t.Run("Server and registered services are serving", func(t *testing.T) {
+		srv, cli := startEchoServer(t, "13023")
+
+		assert.Equal(t, common.HealthServing, check(t, cli, "").Status)
+		assert.Equal(t, common.HealthServing, check(t, cli, "EchoService").Status)
+		assert.Equal(t, common.HealthServing, check(t, cli, "PingHandler").Status)
+		assert.Equal(t, common.HealthUnknown, check(t, cli, "Database").Status)
+
+		srv.SetServingStatus("EchoService", common.HealthNotServing)
+		assert.Equal(t, common.HealthNotServing, check(t, cli, "EchoService").Status)
+		assert.Equal(t, common.HealthNotServing, srv.ServingStatus("EchoService"))
+		assert.Equal(t, common.HealthServing, check(t, cli, "").Status)
+
+		srv.SetServingStatus("Database", common.HealthServing)
+		assert.Equal(t, common.HealthServing, check(t, cli, "Database").Status)
+
+		// the name of the Health service is reserved
+		assert.Error(t, srv.Register(newHealth()))
+	})
+
+	t.Run("Watch answers status changes", func(t *testing.T) {
+		srv, cli := startEchoServer(t, "13024")
+		initial := check(t, cli, "")
+
+		// an outdated version is answered at once
+		res := watched(t, watch(cli, "", initial.Version-1, time.Minute))
+		assert.Equal(t, initial.Version, res.Version)
+
+		call := watch(cli, "", initial.Version, time.Minute)
+		time.Sleep(20 * time.Millisecond)
+		srv.SetServingStatus("", common.HealthServing)
+		srv.SetServingStatus("", common.HealthNotServing)
+		res = watched(t, call)
+		assert.Equal(t, common.HealthNotServing, res.Status)
+		assert.Equal(t, initial.Version+1, res.Version)
+
+		// a service can be watched before it is set, without being added by the watch
+		call = watch(cli, "Database", 0, time.Minute)
+		time.Sleep(20 * time.Millisecond)
+		srv.health.mutex.Lock()
+		assert.NotContains(t, srv.health.services, "Database")
+		srv.health.mutex.Unlock()
+		srv.SetServingStatus("Cache", common.HealthServing)
+		srv.SetServingStatus("Database", common.HealthServing)
+		assert.Equal(t, common.HealthServing, watched(t, call).Status)
+
+		// an unknown service is UNKNOWN once the watch timed out
+		res = watched(t, watch(cli, "Queue", 0, 50*time.Millisecond))
+		assert.Equal(t, common.HealthWatchResponse{Status: common.HealthUnknown}, res)
+		srv.health.mutex.Lock()
+		assert.NotContains(t, srv.health.services, "Queue")
+		srv.health.mutex.Unlock()
+	})
+
+	t.Run("Watch times out with the same version", func(t *testing.T) {
+		_, cli := startEchoServer(t, "13025")
+		initial := check(t, cli, "")
+
+		start := time.Now()
+		res := watched(t, watch(cli, "", initial.Version, 50*time.Millisecond))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, initial.Version, res.Version)
+		assert.Equal(t, common.HealthServing, res.Status)
+	})
+
+	t.Run("Shutdown answers the watches", func(t *testing.T) {
+		srv, cli := startEchoServer(t, "13026")
+		initial := check(t, cli, "EchoService")
+
+		call := watch(cli, "EchoService", initial.Version, time.Minute)
+		time.Sleep(20 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, srv.Shutdown(ctx))
+		assert.Equal(t, common.HealthNotServing, watched(t, call).Status)
+
+		srv.SetServingStatus("EchoService", common.HealthServing)
+		assert.Equal(t, common.HealthNotServing, srv.ServingStatus("EchoService"))
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"micronet/common"
+)
+
+/**
+ * The CallInfo describes a call running through the interceptors
+ * Args is the decoded request as given to the handler, Reply the response pointer it fills
+ */
+type CallInfo struct {
+	ServiceMethod string
+	Args          any
+	Reply         any
+	Peer          common.PeerInfo
+}
+
+/**
+ * An Interceptor wraps every call of the Server's handlers
+ * It calls next to run the following interceptors and the handler, or returns an error to refuse the call
+ * The returned error is sent to the caller in place of the reply
+ */
+type Interceptor func(info *CallInfo, next func() error) error
+
+/**
+ * Use appends interceptors to the Server's chain, the first one used is the outermost
+ * The chain applies to the calls received from now on
+ * @param interceptors are run in order around every handler
+ */
+func (s *Server) Use(interceptors ...Interceptor) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.interceptors = append(s.interceptors[:len(s.interceptors):len(s.interceptors)], interceptors...)
+}
+
+/**
+ * intercept runs a call through the current chain
+ * @param info describes the call
+ * @param handler invokes the handler
+ * @return the error of an interceptor or of the handler
+ */
+func (s *Server) intercept(info *CallInfo, handler func() error) error {
+	s.mutex.Lock()
+	interceptors := s.interceptors
+	s.mutex.Unlock()
+
+	return chain(interceptors, info, handler)
+}
+
+func chain(interceptors []Interceptor, info *CallInfo, handler func() error) error {
+	if len(interceptors) == 0 {
+		return handler()
+	}
+
+	return interceptors[0](info, func() error {
+		return chain(interceptors[1:], info, handler)
+	})
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type EchoService struct{}
+
+func (s *EchoService) Echo(req string, res *string) error {
+	*res = req
+	return nil
+}
+
+func (s *EchoService) Fail(req string, res *string) error {
+	return errors.New(req)
+}
+
+func startInterceptedServer(t *testing.T, port string, interceptors ...Interceptor) *rpc.Client {
+	_, cli := startEchoServer(t, port, interceptors...)
+	return cli
+}
+
+func startEchoServer(t *testing.T, port string, interceptors ...Interceptor) (*Server, *rpc.Client) {
+	srv, err := NewServer(common.NetConf{Name: "intercepted", Protocol: "tcp", Ip: "127.0.0.1", Port: port})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Register(new(EchoService)))
+	assert.NoError(t, srv.Register(new(PanicService)))
+	srv.Use(interceptors...)
+
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+	time.Sleep(ListenReadynessDuration)
+
+	cli, err := rpc.Dial("tcp", "127.0.0.1:"+port)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { cli.Close() })
+
+	return srv, cli
+}
+
+func TestServerInterceptors(t *testing.T) {
+	t.Run("Interceptors run in order around the handler", func(t *testing.T) {
+		var mutex sync.Mutex
+		var trace []string
+		record := func(name string) Interceptor {
+			return func(info *CallInfo, next func() error) error {
+				mutex.Lock()
+				trace = append(trace, name+" before")
+				mutex.Unlock()
+
+				err := next()
+
+				mutex.Lock()
+				trace = append(trace, name+" after")
+				mutex.Unlock()
+				return err
+			}
+		}
+
+		cli := startInterceptedServer(t, "13010", record("outer"), record("inner"))
+
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "hello", &reply))
+		assert.Equal(t, "hello", reply)
+		assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, trace)
+	})
+
+	t.Run("Interceptor sees the call and can alter the reply", func(t *testing.T) {
+		var seen CallInfo
+		cli := startInterceptedServer(t, "13011", func(info *CallInfo, next func() error) error {
+			err := next()
+			seen = *info
+			*info.Reply.(*string) += "!"
+			return err
+		})
+
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "hello", &reply))
+		assert.Equal(t, "hello!", reply)
+		assert.Equal(t, "EchoService.Echo", seen.ServiceMethod)
+		assert.Equal(t, "hello", seen.Args)
+		assert.NotEmpty(t, seen.Peer.PeerAddr)
+	})
+
+	t.Run("Interceptor short-circuits the handler", func(t *testing.T) {
+		called := false
+		cli := startInterceptedServer(t, "13012",
+			func(info *CallInfo, next func() error) error {
+				if info.ServiceMethod == "EchoService.Echo" {
+					return errors.New("unauthorized")
+				}
+				return next()
+			},
+			func(info *CallInfo, next func() error) error {
+				called = true
+				return next()
+			},
+		)
+
+		var reply string
+		err := cli.Call("EchoService.Echo", "hello", &reply)
+		assert.EqualError(t, err, "unauthorized")
+		assert.Empty(t, reply)
+		assert.False(t, called)
+
+		assert.NoError(t, cli.Call("PingHandler.Ping", &common.Ping{}, &common.Pong{}))
+		assert.True(t, called)
+	})
+
+	t.Run("Handler errors go through the chain", func(t *testing.T) {
+		var seen error
+		cli := startInterceptedServer(t, "13013", func(info *CallInfo, next func() error) error {
+			seen = next()
+			return seen
+		})
+
+		var reply string
+		assert.EqualError(t, cli.Call("EchoService.Fail", "boom", &reply), "boom")
+		assert.EqualError(t, seen, "boom")
+	})
+
+	t.Run("Unknown methods are refused", func(t *testing.T) {
+		cli := startInterceptedServer(t, "13014")
+
+		var reply string
+		assert.EqualError(t, cli.Call("EchoService.Missing", "", &reply), "rpc: can't find method EchoService.Missing")
+		assert.EqualError(t, cli.Call("Missing.Echo", "", &reply), "rpc: can't find service Missing.Echo")
+		assert.NoError(t, cli.Call("EchoService.Echo", "still served", &reply))
+	})
+
+	t.Run("Handlers registered under a name go through the chain", func(t *testing.T) {
+		var seen string
+		srv, cli := startEchoServer(t, "13027", func(info *CallInfo, next func() error) error {
+			seen = info.ServiceMethod
+			return next()
+		})
+		assert.NoError(t, srv.RegisterName("Alias", new(EchoService)))
+
+		var reply string
+		assert.NoError(t, cli.Call("Alias.Echo", "aliased", &reply))
+		assert.Equal(t, "aliased", reply)
+		assert.Equal(t, "Alias.Echo", seen)
+	})
+
+	t.Run("Connexions served directly go through the chain", func(t *testing.T) {
+		srv, err := NewServer(common.NetConf{Name: "direct", Protocol: "tcp", Ip: "127.0.0.1", Port: "0"})
+		assert.NoError(t, err)
+		assert.NoError(t, srv.Register(new(EchoService)))
+		assert.NoError(t, srv.Register(new(PanicService)))
+		srv.Use(func(info *CallInfo, next func() error) error {
+			err := next()
+			*info.Reply.(*string) += "!"
+			return err
+		})
+
+		serverConn, clientConn := net.Pipe()
+		go srv.ServeConn(serverConn)
+		cli := rpc.NewClient(clientConn)
+		defer cli.Close()
+
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "direct", &reply))
+		assert.Equal(t, "direct!", reply)
+		err = cli.Call("PanicService.Panic", "boom", &reply)
+		assert.Equal(t, common.MicronetInternalError{ServiceMethod: "PanicService.Panic"}, common.DecodeError(err))
+	})
+}
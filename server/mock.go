@@ -1,9 +1,12 @@
 package server
 
+import "context"
+
 type MockServer struct {
 	RegisterFunc func(rcvr any) error
 	StartFunc    func() error
 	StopFunc     func()
+	ShutdownFunc func(ctx context.Context) error
 }
 
 // Ensure MockServer implements I_Server.
@@ -28,3 +31,10 @@ func (m *MockServer) Stop() {
 		m.StopFunc()
 	}
 }
+
+func (m *MockServer) Shutdown(ctx context.Context) error {
+	if m.ShutdownFunc != nil {
+		return m.ShutdownFunc(ctx)
+	}
+	return nil
+}
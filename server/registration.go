@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"micronet/client"
+	"micronet/common"
+)
+
+// The time given to the registry to deregister a stopping Server
+const deregisterTimeout = time.Second
+
+/**
+ * The RegistrationConf makes a Server register itself with a registry Server when it starts
+ * The Server is listed under its NetConf.Name, at the address it is bound to, with the Metadata
+ * TTL is the requested lease, renewed in the background, and the registry's default when 0
+ * Configure is applied to the Client connecting to the registry, to set its TLS config or retry policy
+ */
+type RegistrationConf struct {
+	Registry  common.NetConf
+	Metadata  map[string]string
+	TTL       time.Duration
+	Configure func(*client.Client)
+}
+
+/**
+ * SetRegistration registers the Server with a registry on Start, and deregisters it on Stop or Shutdown
+ * The registration happens in the background, retried until the registry answers, so that an unreachable registry
+ * neither fails nor delays Start
+ * ClientServer, Publisher and Subscriber inherit it from their Server
+ * @param conf is the registry and the listed instance's metadata
+ */
+func (s *Server) SetRegistration(conf RegistrationConf) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.registrationConf = &conf
+}
+
+/**
+ * Addr is the address the started Server is bound to, nil before Start
+ */
+func (s *Server) Addr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Addr()
+}
+
+/**
+ * register starts listing the Server with the registry of its RegistrationConf, if any, in the background
+ * @param addr is the address of the listener
+ */
+func (s *Server) register(addr net.Addr) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conf := s.registrationConf
+	if conf == nil || s.ctx.Err() != nil {
+		return
+	}
+
+	instance := common.ServiceInstance{NetConf: s.advertised(addr), Metadata: conf.Metadata}
+	registration := client.NewRegistration(conf.Registry, instance, conf.TTL)
+	if conf.Configure != nil {
+		conf.Configure(registration.Client)
+	}
+
+	registration.StartBackground()
+	s.registration = registration
+}
+
+/**
+ * deregister removes the Server from its registry, if it registered
+ * @param ctx bounds the deregistration, at most deregisterTimeout
+ */
+func (s *Server) deregister(ctx context.Context) {
+	s.mutex.Lock()
+	registration := s.registration
+	s.registration = nil
+	s.mutex.Unlock()
+
+	if registration == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deregisterTimeout)
+	defer cancel()
+
+	errStop := registration.StopContext(ctx)
+	if errStop != nil {
+		log.Printf("Error deregistering server %+v: %s", s.NetConf, errStop)
+	}
+}
+
+/**
+ * advertised is the Server's NetConf with the port it is bound to
+ * Without an Ip, the listener's host is advertised, or the hostname if the listener is bound to every interface
+ */
+func (s *Server) advertised(addr net.Addr) common.NetConf {
+	network := s.NetConf
+
+	host, port, errSplit := net.SplitHostPort(addr.String())
+	if errSplit != nil {
+		return network
+	}
+	network.Port = port
+
+	if network.Ip == "" {
+		network.Ip = host
+		if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+			hostname, errHostname := os.Hostname()
+			if errHostname == nil {
+				network.Ip = hostname
+			}
+		}
+	}
+
+	return network
+}
@@ -2,13 +2,22 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
 	"net"
 	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"micronet/client"
+	"micronet/codec"
 	"micronet/common"
 )
 
+// The delay between two checks of the in-flight calls while shutting down
+const shutdownPollInterval = 10 * time.Millisecond
+
 /**
  * The basic Server functions
  */
@@ -16,39 +25,62 @@ type I_Server interface {
 	Register(any) error
 	Start() error
 	Stop()
+	Shutdown(context.Context) error
 }
 
 /**
  * The Server structure is an rpc server with it's network config and context
+ * The embedded rpc.Server validates the registered handlers, the calls are dispatched by the Server through its interceptors
+ * The Server overrides every method of the rpc.Server registering a handler or serving a connexion,
+ * so that no call skips the interceptors, the panic recovery and the in-flight tracking
  */
 type Server struct {
 	*rpc.Server
 	I_Server
 	common.NetConf
-	ctx            context.Context
-	cancelFunction context.CancelFunc
+	ctx              context.Context
+	cancelFunction   context.CancelFunc
+	mutex            sync.Mutex
+	listener         net.Listener
+	conns            map[net.Conn]struct{}
+	inFlight         atomic.Int64
+	tlsConfig        *tls.Config
+	services         sync.Map
+	interceptors     []Interceptor
+	counters         serverCounters
+	codec            codec.Codec
+	registrationConf *RegistrationConf
+	registration     *client.Registration
+	health           *Health
 }
 
 /**
- * NewServer creates an rpc server with it's context and registers the default ping handler
+ * NewServer creates an rpc server with it's context and registers the default ping and Health handlers
  * @param network is the server's configuration
  * @return the initialized Server or error
  */
 func NewServer(network common.NetConf) (*Server, error) {
-	srv := &Server{NetConf: network}
+	srv := &Server{NetConf: network, conns: make(map[net.Conn]struct{})}
 	srv.Server = rpc.NewServer()
 	srv.ctx, srv.cancelFunction = context.WithCancel(context.Background())
+	srv.health = newHealth()
 
 	errRegister := srv.Register(new(common.PingHandler))
 	if errRegister != nil {
 		return nil, errRegister
 	}
 
+	errRegister = srv.Register(srv.health)
+	if errRegister != nil {
+		return nil, errRegister
+	}
+
 	return srv, nil
 }
 
 /**
- * Register any additional handler
+ * Register any additional handler, reported SERVING by the Health service under its type's name
+ * The name "Health" is reserved for the Health service, a handler of that name is refused
  * @param rcvr any structure that implements at leaste one handler prototyped function
  * @return an potential registration error
  */
@@ -58,42 +90,183 @@ func (s *Server) Register(rcvr any) error {
 		return errRegister
 	}
 
+	name, svc := newService(rcvr)
+	s.services.Store(name, svc)
+	s.health.setDefault(name, common.HealthServing)
+
+	return nil
+}
+
+/**
+ * RegisterName registers a handler under the given name instead of its type's name, reported SERVING by the Health service
+ * @param name is the service's name, "Health" is reserved
+ * @param rcvr any structure that implements at leaste one handler prototyped function
+ * @return an potential registration error
+ */
+func (s *Server) RegisterName(name string, rcvr any) error {
+	errRegister := s.Server.RegisterName(name, rcvr)
+	if errRegister != nil {
+		return errRegister
+	}
+
+	_, svc := newService(rcvr)
+	s.services.Store(name, svc)
+	s.health.setDefault(name, common.HealthServing)
+
 	return nil
 }
 
 /**
  * Start the Server that was initialized with a netork config
  * You might consider starting the server in a goroutine
+ * Start returns nil once the server is stopped or shut down
+ * The Server registers itself in the background if SetRegistration was called
  * @return potential networking errors
  */
-func (s *Server) Start() (error) {
+func (s *Server) Start() error {
+	s.mutex.Lock()
+	if s.ctx.Err() != nil {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	serverCodec, errCodec := codec.Lookup(s.Codec)
+	if errCodec != nil {
+		s.mutex.Unlock()
+		return errCodec
+	}
+	s.codec = serverCodec
+
 	listener, errListen := net.Listen(s.Protocol, ":"+s.Port)
 	if errListen != nil {
+		s.mutex.Unlock()
 		return errListen
 	}
+	if s.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	s.listener = listener
+	s.mutex.Unlock()
 	defer listener.Close()
 
+	s.register(listener.Addr())
+
 	log.Printf("Server is running %+v", s.NetConf)
 
 	for {
-		select {
-		case <-s.ctx.Done():
-			return nil
-		default:
-			conn, errAccept := listener.Accept()
-			if errAccept != nil {
-				log.Printf("Error accepting connection: %s", errAccept)
-				continue
+		conn, errAccept := listener.Accept()
+		if errAccept != nil {
+			if s.ctx.Err() != nil {
+				return nil
 			}
-			go s.ServeConn(conn)
+			log.Printf("Error accepting connection: %s", errAccept)
+			continue
+		}
+
+		if !s.trackConn(conn) {
+			conn.Close()
+			continue
 		}
+		go s.serveConn(conn)
 	}
 }
 
 /**
- * Stop the running server
+ * Stop the running server immediately, closing the listener and every open connection, then deregisters it
+ * for at most a second
+ * Use Shutdown() to let in-flight calls complete
  */
 func (s *Server) Stop() {
 	log.Printf("Stoping server %+v", s.NetConf)
+	s.health.shutdown()
+	s.closeListener()
+	s.closeConns()
+	s.deregister(context.Background())
+}
+
+/**
+ * Shutdown gracefully stops the running server
+ * The Server is deregistered, for at most a second, and reported NOT_SERVING, the listener is closed and new calls are refused,
+ * then in-flight calls are awaited
+ * until ctx is done, after which the remaining connections are closed
+ * @param ctx bounds the time given to in-flight calls
+ * @return ctx's error if in-flight calls did not complete in time
+ */
+func (s *Server) Shutdown(ctx context.Context) error {
+	log.Printf("Shutting down server %+v", s.NetConf)
+	s.deregister(ctx)
+	s.health.shutdown()
+	s.closeListener()
+	defer s.closeConns()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for s.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrackConn(conn)
+
+	peer, errPeer := s.peerInfo(conn)
+	if errPeer != nil {
+		log.Printf("Error accepting connection from %s: %s", conn.RemoteAddr(), errPeer)
+		conn.Close()
+		return
+	}
+
+	negotiated, serverCodec, errNegotiate := s.negotiate(conn)
+	if errNegotiate != nil {
+		log.Printf("Error negotiating connection from %s: %s", peer.PeerAddr, errNegotiate)
+		conn.Close()
+		return
+	}
+
+	s.serveCodec(&trackedCodec{ServerCodec: serverCodec.NewServerCodec(negotiated), server: s}, peer)
+}
+
+func (s *Server) trackConn(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ctx.Err() != nil {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+
+	return true
+}
+
+func (s *Server) untrackConn(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.conns, conn)
+}
+
+func (s *Server) closeListener() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.cancelFunction()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+func (s *Server) closeConns() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn := range s.conns {
+		conn.Close()
+	}
 }
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"net/rpc"
 	"testing"
+	"time"
 
 	"micronet/common"
 
@@ -32,4 +35,153 @@ func TestServerLifecycle(t *testing.T) {
 	})
 }
 
-// Add more test functions as needed
+const ListenReadynessDuration time.Duration = time.Millisecond * 100
+
+type SlowService struct{}
+
+func (s *SlowService) Sleep(req time.Duration, res *bool) error {
+	time.Sleep(req)
+	*res = true
+	return nil
+}
+
+func startSlowServer(t *testing.T, port string) (*Server, chan error) {
+	netConf := common.NetConf{
+		Protocol: "tcp",
+		Port:     port,
+		Ip:       "127.0.0.1",
+	}
+
+	server, errNew := NewServer(netConf)
+	if !assert.NoError(t, errNew) {
+		t.FailNow()
+	}
+
+	errRegister := server.Register(new(SlowService))
+	if !assert.NoError(t, errRegister) {
+		t.FailNow()
+	}
+
+	started := make(chan error, 1)
+	go func() {
+		started <- server.Start()
+	}()
+	time.Sleep(ListenReadynessDuration)
+
+	return server, started
+}
+
+func TestServerShutdown(t *testing.T) {
+	t.Run("In-flight calls complete", func(t *testing.T) {
+		server, started := startSlowServer(t, "13001")
+
+		cli, errDial := rpc.Dial("tcp", "127.0.0.1:13001")
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		defer cli.Close()
+
+		var res bool
+		call := cli.Go("SlowService.Sleep", 200*time.Millisecond, &res, nil)
+		time.Sleep(ListenReadynessDuration / 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, server.Shutdown(ctx))
+
+		<-call.Done
+		assert.NoError(t, call.Error)
+		assert.True(t, res)
+
+		select {
+		case errStart := <-started:
+			assert.NoError(t, errStart)
+		case <-time.After(time.Second):
+			t.Error("Start did not return after Shutdown")
+		}
+
+		_, errRedial := rpc.Dial("tcp", "127.0.0.1:13001")
+		assert.Error(t, errRedial)
+	})
+
+	t.Run("Deadline exceeded", func(t *testing.T) {
+		server, started := startSlowServer(t, "13002")
+
+		cli, errDial := rpc.Dial("tcp", "127.0.0.1:13002")
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		defer cli.Close()
+
+		var res bool
+		call := cli.Go("SlowService.Sleep", 2*time.Second, &res, nil)
+		time.Sleep(ListenReadynessDuration / 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, server.Shutdown(ctx), context.DeadlineExceeded)
+
+		select {
+		case <-call.Done:
+			assert.Error(t, call.Error)
+		case <-time.After(time.Second):
+			t.Error("connection was not closed after the deadline")
+		}
+
+		assert.NoError(t, <-started)
+	})
+
+	t.Run("New calls are refused while draining", func(t *testing.T) {
+		server, started := startSlowServer(t, "13003")
+
+		cli, errDial := rpc.Dial("tcp", "127.0.0.1:13003")
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		defer cli.Close()
+
+		var slowRes bool
+		slowCall := cli.Go("SlowService.Sleep", 300*time.Millisecond, &slowRes, nil)
+		time.Sleep(ListenReadynessDuration / 2)
+
+		shutdownDone := make(chan error, 1)
+		go func() {
+			shutdownDone <- server.Shutdown(context.Background())
+		}()
+		time.Sleep(ListenReadynessDuration / 2)
+
+		var res bool
+		errCall := cli.Call("SlowService.Sleep", time.Duration(0), &res)
+		assert.ErrorContains(t, errCall, "shutting down")
+
+		<-slowCall.Done
+		assert.NoError(t, slowCall.Error)
+		assert.NoError(t, <-shutdownDone)
+		assert.NoError(t, <-started)
+	})
+}
+
+func TestServerStop(t *testing.T) {
+	t.Run("Start returns", func(t *testing.T) {
+		server, started := startSlowServer(t, "13004")
+
+		cli, errDial := rpc.Dial("tcp", "127.0.0.1:13004")
+		if !assert.NoError(t, errDial) {
+			t.FailNow()
+		}
+		defer cli.Close()
+
+		server.Stop()
+
+		select {
+		case errStart := <-started:
+			assert.NoError(t, errStart)
+		case <-time.After(time.Second):
+			t.Error("Start did not return after Stop")
+		}
+
+		var res bool
+		errCall := cli.Call("SlowService.Sleep", time.Duration(0), &res)
+		assert.Error(t, errCall)
+	})
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+
+	"micronet/common"
+)
+
+/**
+ * The ServerStats are the counters of the calls handled by a Server since its creation
+ * Failed counts the calls answered with an error, including the panics
+ */
+type ServerStats struct {
+	Calls    int64
+	Failed   int64
+	Panics   int64
+	InFlight int64
+}
+
+/**
+ * The serverCounters are the atomic counters behind ServerStats
+ */
+type serverCounters struct {
+	calls  atomic.Int64
+	failed atomic.Int64
+	panics atomic.Int64
+}
+
+/**
+ * Stats of the calls handled by the Server
+ */
+func (s *Server) Stats() ServerStats {
+	return ServerStats{
+		Calls:    s.counters.calls.Load(),
+		Failed:   s.counters.failed.Load(),
+		Panics:   s.counters.panics.Load(),
+		InFlight: s.inFlight.Load(),
+	}
+}
+
+/**
+ * recoverCall runs fn, turning a panic into a common.MicronetInternalError so that one call cannot crash the Server
+ * The panic and its stack trace are logged
+ * @param serviceMethod is the call's "handler.function"
+ * @param fn runs the call
+ * @return fn's error, or the internal error of a panic
+ */
+func (s *Server) recoverCall(serviceMethod string, fn func() error) (err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		s.counters.panics.Add(1)
+		log.Printf("Panic in %s: %v\n%s", serviceMethod, recovered, debug.Stack())
+		err = common.MicronetInternalError{ServiceMethod: serviceMethod}
+	}()
+
+	return fn()
+}
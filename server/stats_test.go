@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+
+	"micronet/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PanicService struct{}
+
+func (s *PanicService) Panic(req string, res *string) error {
+	panic(req)
+}
+
+func TestServerPanicRecovery(t *testing.T) {
+	t.Run("Panicking handler answers an internal error", func(t *testing.T) {
+		var seen error
+		cli := startInterceptedServer(t, "13015", func(info *CallInfo, next func() error) error {
+			seen = next()
+			return seen
+		})
+
+		var reply string
+		err := cli.Call("PanicService.Panic", "boom", &reply)
+		assert.Equal(t, common.MicronetInternalError{ServiceMethod: "PanicService.Panic"}, common.DecodeError(err))
+		assert.Equal(t, common.MicronetInternalError{ServiceMethod: "PanicService.Panic"}, seen)
+
+		assert.NoError(t, cli.Call("EchoService.Echo", "still served", &reply))
+		assert.Equal(t, "still served", reply)
+	})
+
+	t.Run("Panicking interceptor answers an internal error", func(t *testing.T) {
+		cli := startInterceptedServer(t, "13016", func(info *CallInfo, next func() error) error {
+			panic("interceptor")
+		})
+
+		var reply string
+		err := cli.Call("EchoService.Echo", "hello", &reply)
+		assert.Equal(t, common.MicronetInternalError{ServiceMethod: "EchoService.Echo"}, common.DecodeError(err))
+	})
+}
+
+func TestServerStats(t *testing.T) {
+	t.Run("Calls, failures and panics are counted", func(t *testing.T) {
+		srv, cli := startEchoServer(t, "13017")
+
+		var reply string
+		assert.NoError(t, cli.Call("EchoService.Echo", "hello", &reply))
+		assert.Error(t, cli.Call("EchoService.Fail", "boom", &reply))
+		assert.Error(t, cli.Call("PanicService.Panic", "boom", &reply))
+		assert.Error(t, cli.Call("PanicService.Panic", "boom", &reply))
+
+		assert.Equal(t, ServerStats{Calls: 4, Failed: 3, Panics: 2}, srv.Stats())
+	})
+}
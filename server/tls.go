@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+
+	"micronet/common"
+)
+
+// The time given to a client to complete the TLS handshake
+const handshakeTimeout = 10 * time.Second
+
+/**
+ * SetTLSConfig makes the Server accept TLS connections only
+ * Set config.ClientAuth to tls.RequireAndVerifyClientCert and config.ClientCAs for mutual TLS,
+ * the verified client identity is then given to the handlers through common.PeerInfo
+ * Must be called before Start()
+ * @param config is the server's TLS configuration, nil serves plain TCP
+ */
+func (s *Server) SetTLSConfig(config *tls.Config) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tlsConfig = config
+}
+
+/**
+ * peerInfo completes the TLS handshake, if any, and describes the connection's peer
+ */
+func (s *Server) peerInfo(conn net.Conn) (common.PeerInfo, error) {
+	peer := common.PeerInfo{PeerAddr: conn.RemoteAddr().String()}
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return peer, nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, handshakeTimeout)
+	defer cancel()
+
+	errHandshake := tlsConn.HandshakeContext(ctx)
+	if errHandshake != nil {
+		return peer, errHandshake
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		peer.PeerIdentity = state.VerifiedChains[0][0].Subject.CommonName
+		peer.PeerVerified = true
+	}
+
+	return peer, nil
+}
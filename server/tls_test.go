@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"micronet/common"
+	"micronet/internal/testcert"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type WhoAmIRequest struct {
+	common.PeerInfo
+}
+
+type IdentityService struct{}
+
+func (s *IdentityService) WhoAmI(req *WhoAmIRequest, res *common.PeerInfo) error {
+	*res = req.PeerInfo
+	return nil
+}
+
+func startTLSServer(t *testing.T, port string, config *tls.Config) *Server {
+	srv, err := NewServer(common.NetConf{Name: "tls", Protocol: "tcp", Ip: "127.0.0.1", Port: port})
+	assert.NoError(t, err)
+	assert.NoError(t, srv.Register(new(IdentityService)))
+	srv.SetTLSConfig(config)
+
+	go srv.Start()
+	t.Cleanup(srv.Stop)
+	time.Sleep(ListenReadynessDuration)
+
+	return srv
+}
+
+func dialTLS(port string, config *tls.Config) (*rpc.Client, error) {
+	conn, err := tls.Dial("tcp", "127.0.0.1:"+port, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewClient(conn), nil
+}
+
+func TestServerTLS(t *testing.T) {
+	authority := testcert.NewAuthority(t)
+
+	t.Run("Verified client identity is given to the handler", func(t *testing.T) {
+		startTLSServer(t, "13005", authority.ServerConfig(t))
+
+		cli, err := dialTLS("13005", authority.ClientConfig(t, "alice"))
+		assert.NoError(t, err)
+		defer cli.Close()
+
+		var peer common.PeerInfo
+		err = cli.Call("IdentityService.WhoAmI", &WhoAmIRequest{PeerInfo: common.PeerInfo{PeerIdentity: "mallory"}}, &peer)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", peer.PeerIdentity)
+		assert.True(t, peer.PeerVerified)
+		assert.NotEmpty(t, peer.PeerAddr)
+	})
+
+	t.Run("Client without certificate is rejected", func(t *testing.T) {
+		startTLSServer(t, "13006", authority.ServerConfig(t))
+
+		cli, err := dialTLS("13006", &tls.Config{RootCAs: authority.Pool})
+		if err == nil {
+			defer cli.Close()
+			err = cli.Call("PingHandler.Ping", &common.Ping{}, &common.Pong{})
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("Client certificate from another authority is rejected", func(t *testing.T) {
+		startTLSServer(t, "13007", authority.ServerConfig(t))
+
+		config := testcert.NewAuthority(t).ClientConfig(t, "eve")
+		config.RootCAs = authority.Pool
+		cli, err := dialTLS("13007", config)
+		if err == nil {
+			defer cli.Close()
+			err = cli.Call("PingHandler.Ping", &common.Ping{}, &common.Pong{})
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("Plain connection is rejected", func(t *testing.T) {
+		startTLSServer(t, "13008", authority.ServerConfig(t))
+
+		cli, err := rpc.Dial("tcp", "127.0.0.1:13008")
+		assert.NoError(t, err)
+		defer cli.Close()
+
+		call := cli.Go("PingHandler.Ping", &common.Ping{}, &common.Pong{}, nil)
+		select {
+		case <-call.Done:
+			assert.Error(t, call.Error)
+		case <-time.After(time.Second):
+			t.Fatal("plain call was not rejected")
+		}
+	})
+
+	t.Run("Plain server gives the peer address only", func(t *testing.T) {
+		startTLSServer(t, "13009", nil)
+
+		cli, err := rpc.Dial("tcp", "127.0.0.1:13009")
+		assert.NoError(t, err)
+		defer cli.Close()
+
+		var peer common.PeerInfo
+		assert.NoError(t, cli.Call("IdentityService.WhoAmI", &WhoAmIRequest{}, &peer))
+		assert.NotEmpty(t, peer.PeerAddr)
+		assert.Empty(t, peer.PeerIdentity)
+		assert.False(t, peer.PeerVerified)
+	})
+}